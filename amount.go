@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// coinDecimals is the number of decimal places of an amount of coins
+const coinDecimals = 8
+
+// coin is the number of units in a coin, 10^coinDecimals
+const coin Amount = 100000000
+
+// maxMoney is the most an output, or all the outputs of a transaction, can be worth
+const maxMoney = 21000000 * coin
+
+// errAmountRange is returned by amount arithmetic whose operands or result are negative
+// or worth more than maxMoney
+var errAmountRange = fmt.Errorf("Amount must be between 0 and %s", maxMoney)
+
+// Amount is a monetary value in units, the smallest amount that can be sent.
+// It is printed and parsed in coins, with up to coinDecimals decimal places
+type Amount int64
+
+// MoneyRange checks that an amount is neither negative nor worth more than maxMoney
+func MoneyRange(a Amount) bool {
+	return a >= 0 && a <= maxMoney
+}
+
+// Add returns a + b, or an error when a, b or the sum is out of the money range
+func (a Amount) Add(b Amount) (Amount, error) {
+	if !MoneyRange(a) || !MoneyRange(b) || !MoneyRange(a+b) {
+		return 0, errAmountRange
+	}
+
+	return a + b, nil
+}
+
+// Sub returns a - b, or an error when a, b or the difference is out of the money range
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if !MoneyRange(a) || !MoneyRange(b) || !MoneyRange(a-b) {
+		return 0, errAmountRange
+	}
+
+	return a - b, nil
+}
+
+// MulInt returns a * n, or an error when a or the product is out of the money range
+func (a Amount) MulInt(n int) (Amount, error) {
+	if !MoneyRange(a) || n < 0 || (n > 0 && a > maxMoney/Amount(n)) {
+		return 0, errAmountRange
+	}
+
+	return a * Amount(n), nil
+}
+
+// SumAmounts adds up amounts, or returns an error when any of them or the total is out
+// of the money range
+func SumAmounts(amounts ...Amount) (Amount, error) {
+	var total Amount
+
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// String formats the amount in coins, without trailing zeros, such as 1.2345
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+
+	whole := strconv.FormatUint(units/uint64(coin), 10)
+	fraction := strings.TrimRight(fmt.Sprintf("%0*d", coinDecimals, units%uint64(coin)), "0")
+	if fraction == "" {
+		return sign + whole
+	}
+
+	return sign + whole + "." + fraction
+}
+
+// ParseAmount parses an amount of coins with up to coinDecimals decimal places, such as 1.2345
+func ParseAmount(s string) (Amount, error) {
+	whole, fraction, hasPoint := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" && fraction == "" || hasPoint && fraction == "" {
+		return 0, fmt.Errorf("Invalid amount %q", s)
+	}
+	if len(fraction) > coinDecimals {
+		return 0, fmt.Errorf("Amount %q has more than %d decimal places", s, coinDecimals)
+	}
+
+	for _, digits := range []string{whole, fraction} {
+		if strings.Trim(digits, "0123456789") != "" {
+			return 0, fmt.Errorf("Invalid amount %q", s)
+		}
+	}
+
+	coins, err := parseDigits(whole)
+	if err != nil || coins > uint64(maxMoney/coin) {
+		return 0, fmt.Errorf("Amount %q is more than %s", s, maxMoney)
+	}
+	units, err := parseDigits(fraction + strings.Repeat("0", coinDecimals-len(fraction)))
+	if err != nil {
+		return 0, err
+	}
+
+	amount := Amount(coins)*coin + Amount(units)
+	if !MoneyRange(amount) {
+		return 0, fmt.Errorf("Amount %q is more than %s", s, maxMoney)
+	}
+
+	return amount, nil
+}
+
+// parseDigits parses a string of decimal digits, where an empty string is zero
+func parseDigits(digits string) (uint64, error) {
+	if digits == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(digits, 10, 63)
+	if err != nil {
+		return 0, errAmountRange
+	}
+
+	return n, nil
+}
+
+// Set parses the amount of a command-line flag, implementing flag.Value
+func (a *Amount) Set(s string) error {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+
+	return nil
+}
+
+// UnmarshalJSON parses an amount of coins given as a JSON number or string, such as 1.2345
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	return a.Set(strings.Trim(string(data), `"`))
+}
+
+// MarshalJSON encodes the amount in coins as a JSON number
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmount(t *testing.T) {
+	assert.Equal(t, Amount(math.Pow10(coinDecimals)), coin, "A coin is 10^coinDecimals units")
+
+	for s, expected := range map[string]Amount{
+		"1":          coin,
+		"1.2345":     coin + 2345*coin/10000,
+		"0.00000001": 1,
+		".5":         coin / 2,
+		"21000000":   maxMoney,
+	} {
+		amount, err := ParseAmount(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, amount, s)
+	}
+	for _, s := range []string{"", ".", "1.", "-1", "1e3", "1.000000001", "21000000.00000001", "99999999999999999999"} {
+		_, err := ParseAmount(s)
+		assert.NotNil(t, err, s)
+	}
+
+	assert.Equal(t, "1.2345", (coin + 2345*coin/10000).String())
+	assert.Equal(t, "10", (10 * coin).String())
+	assert.Equal(t, "0.00000001", Amount(1).String())
+	assert.Equal(t, "-0.5", (-coin / 2).String())
+
+	sum, err := SumAmounts(coin, coin/2)
+	assert.Nil(t, err)
+	assert.Equal(t, coin+coin/2, sum)
+	_, err = maxMoney.Add(1)
+	assert.NotNil(t, err)
+	_, err = Amount(-1).Add(1)
+	assert.NotNil(t, err)
+	_, err = coin.Sub(2 * coin)
+	assert.NotNil(t, err)
+	_, err = coin.MulInt(math.MaxInt)
+	assert.NotNil(t, err, "Products that overflow are out of range")
+	fee, err := coin.MulInt(3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*coin, fee)
+}
+
+func TestCheckTransactionMaxMoney(t *testing.T) {
+	script := NewP2PKHScript(make([]byte, 20))
+	tx := &Transaction{nil, []TXInput{{[]byte("tx 1"), 0, nil, sequenceFinal}}, []TXOutput{{maxMoney, script}}, 0}
+	tx.ID = tx.Hash()
+	assert.Nil(t, CheckTransaction(tx))
+
+	tx.Vout = append(tx.Vout, TXOutput{1, script})
+	tx.ID = tx.Hash()
+	assertReason(t, "value-overflow", CheckTransaction(tx))
+	tx.Vout = []TXOutput{{math.MaxInt64, script}, {math.MaxInt64, script}}
+	tx.ID = tx.Hash()
+	assertReason(t, "value-overflow", CheckTransaction(tx))
+}
@@ -3,10 +3,18 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/hex"
 	"log"
 	"time"
 )
 
+// maxBlockSize is the consensus limit on the canonical size of a block, in bytes
+const maxBlockSize = 1000000
+
+// blockHeaderSize is the canonical size of a block header: the previous block hash,
+// the Merkle roots of transaction IDs and witnesses, and the timestamp, nonce and height
+const blockHeaderSize = 32 + 32 + 32 + 8 + 8 + 8
+
 // Block represents a block in the blockchain
 type Block struct {
 	Timestamp     int64
@@ -34,18 +42,80 @@ func NewGenesisBlock(coinbase *Transaction) *Block {
 	return NewBlock([]*Transaction{coinbase}, []byte{}, 0)
 }
 
-// HashTransactions returns a hash of the transactions in the block
+// HashTransactions returns the Merkle root of the IDs of the transactions in the block
 func (b *Block) HashTransactions() []byte {
 	var transactions [][]byte
 
 	for _, tx := range b.Transactions {
-		transactions = append(transactions, tx.Serialize())
+		transactions = append(transactions, tx.Hash())
 	}
 	mTree := NewMerkleTree(transactions)
 
 	return mTree.RootNode.Data
 }
 
+// HashWitnesses returns the Merkle root of the witness hashes of the transactions in the block,
+// which commits to their unlocking scripts
+func (b *Block) HashWitnesses() []byte {
+	var witnesses [][]byte
+
+	for _, tx := range b.Transactions {
+		witnesses = append(witnesses, tx.WitnessHash())
+	}
+	mTree := NewMerkleTree(witnesses)
+
+	return mTree.RootNode.Data
+}
+
+// Size returns the canonical size of the block in bytes
+func (b *Block) Size() int {
+	return blockSize(b.Transactions)
+}
+
+// blockSize returns the canonical size of a block holding the transactions
+func blockSize(transactions []*Transaction) int {
+	size := blockHeaderSize + varIntSize(uint64(len(transactions)))
+	for _, tx := range transactions {
+		size += tx.Size()
+	}
+
+	return size
+}
+
+// NewBlockTemplate chooses the transactions of a new block: the coinbase, then as many
+// candidates as fit in the block size limit, in order, each after the candidates it spends
+// outputs of. Candidates that don't fit are skipped, so that smaller ones after them can
+// still fill the block, and so are the candidates spending their outputs
+func NewBlockTemplate(coinbase *Transaction, candidates []*Transaction) []*Transaction {
+	transactions := []*Transaction{coinbase}
+	txsSize := coinbase.Size()
+	skipped := make(map[string]bool)
+
+	for _, tx := range sortByDependency(candidates) {
+		txSize := tx.Size()
+		if blockHeaderSize+varIntSize(uint64(len(transactions)+1))+txsSize+txSize > maxBlockSize || spendsAny(tx, skipped) {
+			skipped[hex.EncodeToString(tx.ID)] = true
+			continue
+		}
+
+		transactions = append(transactions, tx)
+		txsSize += txSize
+	}
+
+	return transactions
+}
+
+// spendsAny checks whether tx spends an output of one of the transactions in txIDs
+func spendsAny(tx *Transaction, txIDs map[string]bool) bool {
+	for _, vin := range tx.Vin {
+		if txIDs[hex.EncodeToString(vin.Txid)] {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Serialize serializes the block
 func (b *Block) Serialize() []byte {
 	var result bytes.Buffer
@@ -60,14 +130,14 @@ func (b *Block) Serialize() []byte {
 }
 
 // DeserializeBlock deserializes a block
-func DeserializeBlock(d []byte) *Block {
+func DeserializeBlock(d []byte) (*Block, error) {
 	var block Block
 
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(&block)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 
-	return &block
+	return &block, nil
 }
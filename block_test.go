@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSize(t *testing.T) {
+	tx := &Transaction{
+		Vin:  []TXInput{{make([]byte, 32), 1, []byte{1, 2, 3}, sequenceFinal}},
+		Vout: []TXOutput{{5, make([]byte, 25)}},
+	}
+	// inputs: 1 + (1+32) + 4 + (1+3) + 4, outputs: 1 + 8 + (1+25), lock time: 8
+	assert.Equal(t, 89, tx.Size())
+
+	block := &Block{Transactions: []*Transaction{tx, tx}}
+	assert.Equal(t, blockHeaderSize+1+2*89, block.Size())
+}
+
+func TestNewBlockTemplate(t *testing.T) {
+	coinbase := NewCoinbaseTX(string(encodeAddress(version, make([]byte, 20))), "")
+	newTx := func(scriptSize int) *Transaction {
+		return &Transaction{
+			Vin:  []TXInput{{make([]byte, 32), 0, bytes.Repeat([]byte{1}, scriptSize), sequenceFinal}},
+			Vout: []TXOutput{{1, make([]byte, 25)}},
+		}
+	}
+
+	large := newTx(maxBlockSize / 2)
+	small := newTx(100)
+	candidates := []*Transaction{large, large, small, large}
+
+	template := NewBlockTemplate(coinbase, candidates)
+	assert.Equal(t, []*Transaction{coinbase, large, small}, template)
+	assert.True(t, blockSize(template) <= maxBlockSize)
+}
+
+func TestNewBlockTemplateDependencies(t *testing.T) {
+	coinbase := NewCoinbaseTX(string(encodeAddress(version, make([]byte, 20))), "")
+	newTx := func(prevTx *Transaction, scriptSize int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{prevTx.ID, 0, bytes.Repeat([]byte{1}, scriptSize), sequenceFinal}},
+			Vout: []TXOutput{{Amount(scriptSize), make([]byte, 25)}},
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+
+	parent := newTx(coinbase, 100)
+	child := newTx(parent, 100)
+	grandchild := newTx(child, 100)
+	large := newTx(coinbase, maxBlockSize)
+	largeChild := newTx(large, 100)
+
+	template := NewBlockTemplate(coinbase, []*Transaction{grandchild, largeChild, child, large, parent})
+	assert.Equal(t, []*Transaction{coinbase, parent, child, grandchild}, template, "Parents come first, children of skipped transactions are skipped")
+}
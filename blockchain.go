@@ -2,12 +2,12 @@ package main
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -16,10 +16,15 @@ const dbFile = "blockchain_%s.db"
 const blocksBucket = "blocks"
 const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
 
-// Blockchain implements interactions with a DB
+// genesisKey is the key of the genesis block hash in the blocks bucket
+const genesisKey = "g"
+
+// Blockchain implements interactions with a DB. chainID, the hash of its genesis block, is
+// the chain that signatures are made for
 type Blockchain struct {
-	tip []byte
-	db  *bolt.DB
+	tip     []byte
+	db      *bolt.DB
+	chainID []byte
 }
 
 // CreateBlockchain creates a new blockchain DB
@@ -55,6 +60,11 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 		if err != nil {
 			log.Panic(err)
 		}
+
+		err = b.Put([]byte(genesisKey), genesis.Hash)
+		if err != nil {
+			log.Panic(err)
+		}
 		tip = genesis.Hash
 
 		return nil
@@ -63,7 +73,7 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc := Blockchain{tip, db, genesis.Hash}
 
 	return &bc
 }
@@ -82,9 +92,14 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
+	var genesisHash []byte
+	outdated := false
 	err = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		tip = b.Get([]byte("l"))
+		genesisHash = append([]byte{}, b.Get([]byte(genesisKey))...)
+		outdated = !bytes.Equal(b.Get([]byte(chainstateVersionKey)), []byte{chainstateVersion}) ||
+			tx.Bucket([]byte(addrIndexBucket)) == nil
 
 		return nil
 	})
@@ -92,11 +107,46 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc := Blockchain{tip, db, genesisHash}
+	if len(genesisHash) == 0 {
+		bc.chainID = bc.saveGenesisHash()
+	}
+	// Databases saved in an older chainstate format, or without the address index, are
+	// reindexed to rebuild both
+	if outdated {
+		UTXOSet{&bc}.Reindex()
+	}
 
 	return &bc
 }
 
+// saveGenesisHash finds the genesis block of a blockchain created before its hash was
+// saved, and saves its hash
+func (bc *Blockchain) saveGenesisHash() []byte {
+	var genesisHash []byte
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if len(block.PrevBlockHash) == 0 {
+			genesisHash = block.Hash
+			break
+		}
+	}
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+
+		return b.Put([]byte(genesisKey), genesisHash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return genesisHash
+}
+
 // AddBlock saves the block into the blockchain
 func (bc *Blockchain) AddBlock(block *Block) {
 	err := bc.db.Update(func(tx *bolt.Tx) error {
@@ -115,7 +165,10 @@ func (bc *Blockchain) AddBlock(block *Block) {
 
 		lastHash := b.Get([]byte("l"))
 		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
+		lastBlock, err := DeserializeBlock(lastBlockData)
+		if err != nil {
+			return err
+		}
 
 		if block.Height > lastBlock.Height {
 			err = b.Put([]byte("l"), block.Hash)
@@ -132,6 +185,58 @@ func (bc *Blockchain) AddBlock(block *Block) {
 	}
 }
 
+// AcceptBlock validates a block received from a peer, adds it to the blockchain and updates
+// the UTXO set. Its transactions are checked against the UTXO set, so only a block extending
+// the tip is accepted. A block already in the blockchain is ignored
+func (bc *Blockchain) AcceptBlock(block *Block) error {
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		return nil
+	}
+
+	if len(block.Transactions) == 0 {
+		return validationError("bad-blk-length", "block %x has no transactions", block.Hash)
+	}
+	pow := NewProofOfWork(block)
+	if !bytes.Equal(pow.Hash(), block.Hash) {
+		return validationError("bad-hash", "block %x doesn't match the hash of its header, transactions and witnesses", block.Hash)
+	}
+	if !pow.Validate() {
+		return validationError("high-hash", "block %x doesn't meet the proof of work target", block.Hash)
+	}
+
+	tip, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(block.PrevBlockHash, tip.Hash) || block.Height != tip.Height+1 {
+		return validationError("bad-prevblk", "block %x at height %d doesn't extend the tip %x at height %d", block.Hash, block.Height, tip.Hash, tip.Height)
+	}
+
+	if size := block.Size(); size > maxBlockSize {
+		return validationError("block-size", "block %x is %d bytes, more than the maximum of %d", block.Hash, size, maxBlockSize)
+	}
+	UTXOSet := UTXOSet{bc}
+	for _, tx := range block.Transactions {
+		if !tx.IsFinal(block.Height, block.Timestamp) {
+			return validationError("non-final", "block %x contains transaction %x locked until %d", block.Hash, tx.ID, tx.LockTime)
+		}
+		if !UTXOSet.CheckSequenceLocks(tx, block.Height, block.Timestamp) {
+			return validationError("sequence-locks", "block %x contains transaction %x spending outputs that are not old enough", block.Hash, tx.ID)
+		}
+	}
+	if err := CheckBlockTransactions(block.Transactions, UTXOSet); err != nil {
+		return err
+	}
+	if err := bc.VerifyTransactions(block.Transactions); err != nil {
+		return err
+	}
+
+	bc.AddBlock(block)
+	UTXOSet.Update(block)
+
+	return nil
+}
+
 // FindTransaction finds a transaction by its ID
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	bci := bc.Iterator()
@@ -150,7 +255,38 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 		}
 	}
 
-	return Transaction{}, errors.New("Transaction is not found")
+	return Transaction{}, TxNotFoundError{ID}
+}
+
+// DataAnchor locates a data-carrier output in the blockchain
+type DataAnchor struct {
+	Block *Block
+	TxID  []byte
+	Vout  int
+}
+
+// FindDataAnchors finds all data-carrier outputs carrying data, newest first
+func (bc *Blockchain) FindDataAnchors(data []byte) []DataAnchor {
+	var anchors []DataAnchor
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for outIdx, out := range tx.Vout {
+				if bytes.Compare(extractDataCarrier(out.ScriptPubKey), data) == 0 {
+					anchors = append(anchors, DataAnchor{block, tx.ID, outIdx})
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return anchors
 }
 
 // FindUTXO finds all unspent transaction outputs and returns transactions with spent outputs removed
@@ -167,6 +303,10 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
@@ -177,7 +317,10 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 				}
 
 				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
+				if outs.Outputs == nil {
+					outs = TXOutputs{make(map[int]TXOutput), block.Height, block.Timestamp}
+				}
+				outs.Outputs[outIdx] = out
 				UTXO[txID] = outs
 			}
 
@@ -212,7 +355,11 @@ func (bc *Blockchain) GetBestHeight() int {
 		b := tx.Bucket([]byte(blocksBucket))
 		lastHash := b.Get([]byte("l"))
 		blockData := b.Get(lastHash)
-		lastBlock = *DeserializeBlock(blockData)
+		block, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
+		lastBlock = *block
 
 		return nil
 	})
@@ -236,7 +383,11 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 			return errors.New("Block is not found.")
 		}
 
-		block = *DeserializeBlock(blockData)
+		decoded, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
+		block = *decoded
 
 		return nil
 	})
@@ -265,16 +416,25 @@ func (bc *Blockchain) GetBlockHashes() [][]byte {
 	return blocks
 }
 
-// MineBlock mines a new block with the provided transactions
-func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
+// MineBlock mines a new block with the provided transactions. The transactions after the
+// coinbase are ordered so that each comes after the transactions it spends outputs of
+func (bc *Blockchain) MineBlock(transactions []*Transaction) (*Block, error) {
 	var lastHash []byte
 	var lastHeight int
 
-	for _, tx := range transactions {
-		// TODO: ignore transaction if it's not valid
-		if bc.VerifyTransaction(tx) != true {
-			log.Panic("ERROR: Invalid transaction")
-		}
+	if len(transactions) > 1 {
+		transactions = append([]*Transaction{transactions[0]}, sortByDependency(transactions[1:])...)
+	}
+
+	if size := blockSize(transactions); size > maxBlockSize {
+		return nil, validationError("block-size", "block of %d bytes exceeds the maximum of %d", size, maxBlockSize)
+	}
+
+	if err := CheckBlockTransactions(transactions, UTXOSet{bc}); err != nil {
+		return nil, err
+	}
+	if err := bc.VerifyTransactions(transactions); err != nil {
+		return nil, err
 	}
 
 	err := bc.db.View(func(tx *bolt.Tx) error {
@@ -282,7 +442,10 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		lastHash = b.Get([]byte("l"))
 
 		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
+		block, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
 
 		lastHeight = block.Height
 
@@ -292,6 +455,17 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		log.Panic(err)
 	}
 
+	now := time.Now().Unix()
+	UTXOSet := UTXOSet{bc}
+	for _, tx := range transactions {
+		if !tx.IsFinal(lastHeight+1, now) {
+			return nil, validationError("non-final", "transaction %x is locked until %d", tx.ID, tx.LockTime)
+		}
+		if !UTXOSet.CheckSequenceLocks(tx, lastHeight+1, now) {
+			return nil, validationError("sequence-locks", "transaction %x spends outputs that are not old enough", tx.ID)
+		}
+	}
+
 	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
 
 	err = bc.db.Update(func(tx *bolt.Tx) error {
@@ -314,41 +488,105 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		log.Panic(err)
 	}
 
-	return newBlock
+	return newBlock, nil
 }
 
-// SignTransaction signs inputs of a Transaction
-func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
-	prevTXs := make(map[string]Transaction)
+// SignTransaction signs the inputs of a Transaction that privKey can spend.
+// The inputs can spend outputs of mempool transactions
+func (bc *Blockchain) SignTransaction(tx *Transaction, privKey PrivateKey, hashType SigHashType) error {
+	prevTXs, err := bc.findPrevTransactions(tx, Mempool{bc}.Transactions())
+	if err != nil {
+		return err
+	}
 
-	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
-		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	return tx.Sign(privKey, hashType, prevTXs, bc.chainID)
+}
+
+// SignMultisigTransaction signs multisig inputs of a Transaction with several keys
+func (bc *Blockchain) SignMultisigTransaction(tx *Transaction, privKeys []PrivateKey, redeemScript []byte) error {
+	prevTXs, err := bc.findPrevTransactions(tx, Mempool{bc}.Transactions())
+	if err != nil {
+		return err
 	}
 
-	tx.Sign(privKey, prevTXs)
+	return tx.SignMultisig(privKeys, redeemScript, prevTXs, bc.chainID)
 }
 
-// VerifyTransaction verifies transaction input signatures
-func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+// VerifyTransaction verifies transaction input signatures. The inputs can spend outputs
+// of mempool transactions
+func (bc *Blockchain) VerifyTransaction(tx *Transaction) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
+	prevTXs, err := bc.findPrevTransactions(tx, Mempool{bc}.Transactions())
+	if err != nil {
+		return err
+	}
+
+	return tx.Verify(prevTXs, bc.chainID)
+}
+
+// VerifyTransactions verifies the input signatures of the transactions of a block, checking
+// inputs of different transactions in parallel. The inputs can spend outputs of other
+// transactions in the block
+func (bc *Blockchain) VerifyTransactions(transactions []*Transaction) error {
+	var checkers []txChecker
+
+	for _, tx := range transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		prevTXs, err := bc.findPrevTransactions(tx, transactions)
+		if err != nil {
+			return err
+		}
+		txCheckers, err := tx.inputCheckers(prevTXs, bc.chainID)
+		if err != nil {
+			return err
+		}
+
+		checkers = append(checkers, txCheckers...)
+	}
+
+	if !verifyInputs(checkers) {
+		return validationError("bad-signature", "a transaction has an input script that fails")
+	}
+
+	return nil
+}
+
+// findPrevTransactions finds the transactions whose outputs are spent by tx, among the
+// unconfirmed transactions in pending or in the blockchain
+func (bc *Blockchain) findPrevTransactions(tx *Transaction, pending []*Transaction) (map[string]Transaction, error) {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
+		if parent := findPending(pending, vin.Txid); parent != nil {
+			prevTXs[hex.EncodeToString(parent.ID)] = *parent
+			continue
+		}
+
 		prevTX, err := bc.FindTransaction(vin.Txid)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return prevTXs, nil
+}
+
+// findPending returns the transaction in pending with the given ID, if any
+func findPending(pending []*Transaction, ID []byte) *Transaction {
+	for _, tx := range pending {
+		if bytes.Equal(tx.ID, ID) {
+			return tx
+		}
+	}
+
+	return nil
 }
 
 func dbExists(dbFile string) bool {
@@ -19,9 +19,11 @@ func (i *BlockchainIterator) Next() *Block {
 	err := i.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		encodedBlock := b.Get(i.currentHash)
-		block = DeserializeBlock(encodedBlock)
 
-		return nil
+		var err error
+		block, err = DeserializeBlock(encodedBlock)
+
+		return err
 	})
 
 	if err != nil {
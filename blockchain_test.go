@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptBlock(t *testing.T) {
+	wallet := NewWallet(SchemeP256)
+	to := NewWallet(SchemeP256)
+	bc := newTestBlockchain(t, wallet)
+	UTXOSet := UTXOSet{bc}
+	genesis, err := bc.GetBlock(bc.tip)
+	assert.Nil(t, err)
+
+	tx, err := NewUTXOTransaction(wallet, string(to.GetAddress()), 3*coin, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+	assert.Nil(t, err)
+	doubleSpend, err := NewUTXOTransaction(wallet, string(to.GetAddress()), 5*coin, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+	assert.Nil(t, err)
+
+	tip, err := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), tx})
+	assert.Nil(t, err)
+	UTXOSet.Update(tip)
+
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), doubleSpend}, genesis.Hash, tip.Height+1)
+	assertReason(t, "bad-prevblk", bc.AcceptBlock(fork))
+	assert.Equal(t, tip.Hash, bc.tip, "A higher block that doesn't extend the tip is not added")
+
+	overspend := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), doubleSpend}, tip.Hash, tip.Height+1)
+	assertReason(t, "missing-inputs", bc.AcceptBlock(overspend))
+	assert.Equal(t, tip.Hash, bc.tip)
+
+	toScript := NewP2PKHScript(HashPubKey(to.PublicKey))
+	spend, err := NewUTXOTransaction(to, string(wallet.GetAddress()), 2*coin, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+	assert.Nil(t, err)
+	forged := *spend
+	forged.Vin = []TXInput{spend.Vin[0]}
+	forged.Vin[0].ScriptSig = NewP2PKHUnlockingScript([]byte("forged\x01"), to.PublicKey)
+	block := NewBlock([]*Transaction{NewCoinbaseTX(string(to.GetAddress()), ""), &forged}, tip.Hash, tip.Height+1)
+	assertReason(t, "bad-signature", bc.AcceptBlock(block))
+
+	locked := *spend
+	locked.Vin = []TXInput{spend.Vin[0]}
+	locked.Vin[0].Sequence = 5
+	locked.ID = locked.Hash()
+	block = NewBlock([]*Transaction{NewCoinbaseTX(string(to.GetAddress()), ""), &locked}, tip.Hash, tip.Height+1)
+	assertReason(t, "sequence-locks", bc.AcceptBlock(block))
+
+	block = NewBlock([]*Transaction{NewCoinbaseTX(string(to.GetAddress()), ""), spend}, tip.Hash, tip.Height+1)
+	tampered := *block
+	tampered.Nonce++
+	assertReason(t, "bad-hash", bc.AcceptBlock(&tampered))
+	tampered.Hash = NewProofOfWork(&tampered).Hash()
+	assertReason(t, "high-hash", bc.AcceptBlock(&tampered))
+
+	malleated := *spend
+	malleated.Vin = append([]TXInput{}, spend.Vin...)
+	malleated.Vin[0].ScriptSig = forged.Vin[0].ScriptSig
+	tampered = *block
+	tampered.Transactions = []*Transaction{block.Transactions[0], &malleated}
+	assertReason(t, "bad-hash", bc.AcceptBlock(&tampered))
+	assert.Equal(t, tip.Hash, bc.tip, "A block commits to the witnesses of its transactions")
+
+	assert.Nil(t, bc.AcceptBlock(block))
+	assert.Equal(t, block.Hash, bc.tip)
+	assert.Equal(t, []TXOutput{{coin, toScript}, {subsidy, toScript}}, sortOutputs(UTXOSet.FindUTXO(toScript)), "The UTXO set is updated")
+	assert.Nil(t, bc.AcceptBlock(block), "A known block is ignored")
+}
+
+// sortOutputs orders outputs by value
+func sortOutputs(outs []TXOutput) []TXOutput {
+	sort.Slice(outs, func(i, j int) bool { return outs[i].Value < outs[j].Value })
+
+	return outs
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
@@ -14,12 +15,35 @@ type CLI struct{}
 func (cli *CLI) printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  createmultisig -required M -keys KEY1,KEY2,... -p2sh - Create an M-of-N multisig address from wallet addresses or hex public keys. Wrap it in a P2SH address stored in the wallet file, when -p2sh is set, as needed for more than 3 keys.")
+	fmt.Println("  createrawtransaction -inputs TXID:VOUT[:SEQUENCE],... -outputs ADDRESS:AMOUNT,... -locktime LOCKTIME - Print an unsigned hex transaction with the given inputs and outputs")
+	fmt.Println("  createwallet -scheme SCHEME - Generates a new key-pair and saves it into the wallet file. SCHEME is p256 (default), secp256k1 or schnorr")
+	fmt.Println("  decoderawtransaction -hex HEX - Print a hex transaction")
+	fmt.Println("  findanchor -data DATA - Find the transactions that anchored hex DATA on-chain")
 	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  getchainid - Print the chain ID that signatures are made for, the hash of the genesis block")
+	fmt.Println("  htlc create -from FROM -to TO -amount AMOUNT -hash HASH -timeout TIMEOUT -mine - Lock AMOUNT of coins from FROM in an HTLC that TO can claim with the preimage of HASH, or FROM can refund after TIMEOUT. A random secret is generated when -hash is not set.")
+	fmt.Println("  htlc claim -txid TXID -vout VOUT -preimage PREIMAGE -mine - Claim an HTLC output by revealing the preimage of its hash")
+	fmt.Println("  htlc refund -txid TXID -vout VOUT -mine - Refund an HTLC output to its sender once its timeout has passed")
 	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
 	fmt.Println("  printchain - Print all the blocks of the blockchain")
+	fmt.Println("  psbt create -hex HEX -chainid CHAINID - Print a base64 partially signed transaction (PSBT) for an unsigned hex transaction, to be signed for the chain CHAINID. The chain ID of the node's blockchain is used when -chainid is not set.")
+	fmt.Println("  psbt update -psbt PSBT -prevouts TXID:VOUT:SCRIPT:AMOUNT,... -chain - Add the outputs spent by the inputs, and redeem scripts from the wallet file. Look the outputs up in the UTXO set, when -chain is set.")
+	fmt.Println("  psbt sign -psbt PSBT -sighash SIGHASH - Add signatures made with keys from the wallet file. No blockchain is needed: the PSBT carries the chain ID.")
+	fmt.Println("  psbt combine -psbts PSBT1,PSBT2,... - Merge PSBTs of the same transaction signed by different parties")
+	fmt.Println("  psbt finalize -psbt PSBT - Build the unlocking scripts of the inputs that have enough signatures")
+	fmt.Println("  psbt extract -psbt PSBT - Print the signed hex transaction of a finalized PSBT")
 	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -data DATA -locktime LOCKTIME -coins STRATEGY -fee FEE -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
+	fmt.Println("    Inputs are chosen by STRATEGY: bnb (default), largest, smallest or privacy. Each input pays FEE coins.")
+	fmt.Printf("    DATA is up to %d hex-encoded bytes anchored in an unspendable output.\n", maxDataCarrierSize)
+	fmt.Println("    LOCKTIME is a block height, or a UNIX time when it is at least 500000000. The transaction can only be mined after it.")
+	fmt.Println("  sendmany -from FROM -file FILE -coins STRATEGY -fee FEE -mine - Send coins from FROM to every address listed in FILE, a JSON array of {\"address\", \"amount\"} objects or CSV rows of address,amount, in a single transaction")
+	fmt.Println("  sendmultisig -from FROM -to TO -amount AMOUNT -signers ADDR1,ADDR2,... -mine - Send AMOUNT of coins from multisig address FROM to TO, signed by SIGNERS")
+	fmt.Println("  sendrawtransaction -hex HEX - Send a signed hex transaction to the central node")
+	fmt.Println("  signrawtransaction -hex HEX -prevouts TXID:VOUT:SCRIPT:AMOUNT,... -sighash SIGHASH -chainid CHAINID - Sign the inputs of a hex transaction for the chain CHAINID with keys from the wallet file.")
+	fmt.Println("    Every input's previous output is given as SCRIPT, an address or a hex locking script, and AMOUNT. SIGHASH is ALL (default), NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	fmt.Println("    The chain ID of the node's blockchain is used when -chainid is not set, so it's needed to sign without a blockchain.")
 	fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
 }
 
@@ -30,6 +54,36 @@ func (cli *CLI) validateArgs() {
 	}
 }
 
+// exitOnError reports an error that stops a command, such as missing funds, and exits
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseChainID decodes the hex ID of the chain that signatures are made for. When it's empty,
+// the chain ID of the node's blockchain is used, if the node has one
+func parseChainID(chainIDHex, nodeID string) []byte {
+	if chainIDHex == "" {
+		if !dbExists(fmt.Sprintf(dbFile, nodeID)) {
+			exitOnError(fmt.Errorf("%s: set -chainid, printed by getchainid", errNoChainID))
+		}
+
+		bc := NewBlockchain(nodeID)
+		defer bc.db.Close()
+
+		return bc.chainID
+	}
+
+	chainID, err := hex.DecodeString(chainIDHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return chainID
+}
+
 // Run parses command line arguments and processes commands
 func (cli *CLI) Run() {
 	cli.validateArgs()
@@ -40,39 +94,159 @@ func (cli *CLI) Run() {
 		os.Exit(1)
 	}
 
+	findAnchorCmd := flag.NewFlagSet("findanchor", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getChainIDCmd := flag.NewFlagSet("getchainid", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	createMultisigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	htlcCreateCmd := flag.NewFlagSet("htlc create", flag.ExitOnError)
+	htlcClaimCmd := flag.NewFlagSet("htlc claim", flag.ExitOnError)
+	htlcRefundCmd := flag.NewFlagSet("htlc refund", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	psbtCreateCmd := flag.NewFlagSet("psbt create", flag.ExitOnError)
+	psbtUpdateCmd := flag.NewFlagSet("psbt update", flag.ExitOnError)
+	psbtSignCmd := flag.NewFlagSet("psbt sign", flag.ExitOnError)
+	psbtCombineCmd := flag.NewFlagSet("psbt combine", flag.ExitOnError)
+	psbtFinalizeCmd := flag.NewFlagSet("psbt finalize", flag.ExitOnError)
+	psbtExtractCmd := flag.NewFlagSet("psbt extract", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	sendMultisigCmd := flag.NewFlagSet("sendmultisig", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
 
+	findAnchorData := findAnchorCmd.String("data", "", "Hex data to look for")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	createMultisigRequired := createMultisigCmd.Int("required", 0, "Number of signatures required to spend")
+	createMultisigKeys := createMultisigCmd.String("keys", "", "Comma-separated wallet addresses or hex public keys")
+	createMultisigP2SH := createMultisigCmd.Bool("p2sh", false, "Create a pay-to-script-hash address")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Comma-separated outpoints TXID:VOUT, optionally followed by :SEQUENCE")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Comma-separated ADDRESS:AMOUNT pairs")
+	createRawTxLockTime := createRawTxCmd.Int64("locktime", 0, "Block height or UNIX time after which the transaction can be mined")
+	decodeRawTxHex := decodeRawTxCmd.String("hex", "", "Hex transaction")
+	createWalletScheme := createWalletCmd.String("scheme", SchemeP256.String(), "Key scheme: p256, secp256k1 or schnorr")
+	htlcCreateFrom := htlcCreateCmd.String("from", "", "Sender wallet address")
+	htlcCreateTo := htlcCreateCmd.String("to", "", "Recipient wallet address")
+	htlcCreateAmount := new(Amount)
+	htlcCreateCmd.Var(htlcCreateAmount, "amount", "Amount to lock, in coins")
+	htlcCreateHash := htlcCreateCmd.String("hash", "", "Hex SHA-256 hash of the secret")
+	htlcCreateTimeout := htlcCreateCmd.Int64("timeout", 0, "Block height or UNIX time after which the sender can refund")
+	htlcCreateMine := htlcCreateCmd.Bool("mine", false, "Mine immediately on the same node")
+	htlcClaimTxID := htlcClaimCmd.String("txid", "", "ID of the transaction with the HTLC output")
+	htlcClaimVout := htlcClaimCmd.Int("vout", 0, "Index of the HTLC output")
+	htlcClaimPreimage := htlcClaimCmd.String("preimage", "", "Hex secret whose hash locks the output")
+	htlcClaimMine := htlcClaimCmd.Bool("mine", false, "Mine immediately on the same node")
+	htlcRefundTxID := htlcRefundCmd.String("txid", "", "ID of the transaction with the HTLC output")
+	htlcRefundVout := htlcRefundCmd.Int("vout", 0, "Index of the HTLC output")
+	htlcRefundMine := htlcRefundCmd.Bool("mine", false, "Mine immediately on the same node")
+	psbtCreateHex := psbtCreateCmd.String("hex", "", "Unsigned hex transaction")
+	psbtCreateChainID := psbtCreateCmd.String("chainid", "", "Hex ID of the chain to sign for")
+	psbtUpdatePSBT := psbtUpdateCmd.String("psbt", "", "Base64 PSBT")
+	psbtUpdatePrevOuts := psbtUpdateCmd.String("prevouts", "", "Comma-separated TXID:VOUT:SCRIPT:AMOUNT previous outputs")
+	psbtUpdateChain := psbtUpdateCmd.Bool("chain", false, "Look previous outputs up in the UTXO set")
+	psbtSignPSBT := psbtSignCmd.String("psbt", "", "Base64 PSBT")
+	psbtSignSigHash := psbtSignCmd.String("sighash", "ALL", "Signature hash type of inputs that don't require one")
+	psbtCombinePSBTs := psbtCombineCmd.String("psbts", "", "Comma-separated base64 PSBTs")
+	psbtFinalizePSBT := psbtFinalizeCmd.String("psbt", "", "Base64 PSBT")
+	psbtExtractPSBT := psbtExtractCmd.String("psbt", "", "Base64 PSBT")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
-	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendAmount := new(Amount)
+	sendCmd.Var(sendAmount, "amount", "Amount to send, in coins")
+	sendData := sendCmd.String("data", "", "Hex data to anchor in the transaction")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height or UNIX time after which the transaction can be mined")
+	sendCoins := sendCmd.String("coins", "bnb", "Coin selection strategy: bnb, largest, smallest or privacy")
+	sendFee := new(Amount)
+	sendCmd.Var(sendFee, "fee", "Fee paid by each input, in coins")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyFile := sendManyCmd.String("file", "", "JSON or CSV file listing addresses and amounts")
+	sendManyCoins := sendManyCmd.String("coins", "bnb", "Coin selection strategy: bnb, largest, smallest or privacy")
+	sendManyFee := new(Amount)
+	sendManyCmd.Var(sendManyFee, "fee", "Fee paid by each input, in coins")
+	sendManyMine := sendManyCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendMultisigFrom := sendMultisigCmd.String("from", "", "Source multisig address")
+	sendMultisigTo := sendMultisigCmd.String("to", "", "Destination wallet address")
+	sendMultisigAmount := new(Amount)
+	sendMultisigCmd.Var(sendMultisigAmount, "amount", "Amount to send, in coins")
+	sendMultisigSigners := sendMultisigCmd.String("signers", "", "Comma-separated wallet addresses that sign the spend")
+	sendMultisigMine := sendMultisigCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendRawTxHex := sendRawTxCmd.String("hex", "", "Signed hex transaction")
+	signRawTxHex := signRawTxCmd.String("hex", "", "Hex transaction")
+	signRawTxPrevOuts := signRawTxCmd.String("prevouts", "", "Comma-separated TXID:VOUT:SCRIPT:AMOUNT previous outputs")
+	signRawTxSigHash := signRawTxCmd.String("sighash", "ALL", "Signature hash type")
+	signRawTxChainID := signRawTxCmd.String("chainid", "", "Hex ID of the chain to sign for")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
 
 	switch os.Args[1] {
+	case "findanchor":
+		err := findAnchorCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "getbalance":
 		err := getBalanceCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "getchainid":
+		err := getChainIDCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createblockchain":
 		err := createBlockchainCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "createmultisig":
+		err := createMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createwallet":
 		err := createWalletCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "decoderawtransaction":
+		err := decodeRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "htlc":
+		if len(os.Args) < 3 {
+			cli.printUsage()
+			os.Exit(1)
+		}
+
+		var err error
+		switch os.Args[2] {
+		case "create":
+			err = htlcCreateCmd.Parse(os.Args[3:])
+		case "claim":
+			err = htlcClaimCmd.Parse(os.Args[3:])
+		case "refund":
+			err = htlcRefundCmd.Parse(os.Args[3:])
+		default:
+			cli.printUsage()
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Panic(err)
+		}
 	case "listaddresses":
 		err := listAddressesCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -83,6 +257,33 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "psbt":
+		if len(os.Args) < 3 {
+			cli.printUsage()
+			os.Exit(1)
+		}
+
+		var err error
+		switch os.Args[2] {
+		case "create":
+			err = psbtCreateCmd.Parse(os.Args[3:])
+		case "update":
+			err = psbtUpdateCmd.Parse(os.Args[3:])
+		case "sign":
+			err = psbtSignCmd.Parse(os.Args[3:])
+		case "combine":
+			err = psbtCombineCmd.Parse(os.Args[3:])
+		case "finalize":
+			err = psbtFinalizeCmd.Parse(os.Args[3:])
+		case "extract":
+			err = psbtExtractCmd.Parse(os.Args[3:])
+		default:
+			cli.printUsage()
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Panic(err)
+		}
 	case "reindexutxo":
 		err := reindexUTXOCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -93,6 +294,26 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmultisig":
+		err := sendMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
 		err := startNodeCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -103,6 +324,14 @@ func (cli *CLI) Run() {
 		os.Exit(1)
 	}
 
+	if findAnchorCmd.Parsed() {
+		if *findAnchorData == "" {
+			findAnchorCmd.Usage()
+			os.Exit(1)
+		}
+		cli.findAnchor(*findAnchorData, nodeID)
+	}
+
 	if getBalanceCmd.Parsed() {
 		if *getBalanceAddress == "" {
 			getBalanceCmd.Usage()
@@ -111,6 +340,10 @@ func (cli *CLI) Run() {
 		cli.getBalance(*getBalanceAddress, nodeID)
 	}
 
+	if getChainIDCmd.Parsed() {
+		cli.getChainID(nodeID)
+	}
+
 	if createBlockchainCmd.Parsed() {
 		if *createBlockchainAddress == "" {
 			createBlockchainCmd.Usage()
@@ -119,8 +352,43 @@ func (cli *CLI) Run() {
 		cli.createBlockchain(*createBlockchainAddress, nodeID)
 	}
 
+	if createMultisigCmd.Parsed() {
+		if *createMultisigRequired <= 0 || *createMultisigKeys == "" {
+			createMultisigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createMultisig(*createMultisigRequired, *createMultisigKeys, *createMultisigP2SH, nodeID)
+	}
+
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		cli.createWallet(*createWalletScheme, nodeID)
+	}
+
+	if htlcCreateCmd.Parsed() {
+		if *htlcCreateFrom == "" || *htlcCreateTo == "" || *htlcCreateAmount <= 0 || *htlcCreateTimeout <= 0 {
+			htlcCreateCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.htlcCreate(*htlcCreateFrom, *htlcCreateTo, *htlcCreateAmount, *htlcCreateHash, *htlcCreateTimeout, nodeID, *htlcCreateMine)
+	}
+
+	if htlcClaimCmd.Parsed() {
+		if *htlcClaimTxID == "" || *htlcClaimVout < 0 || *htlcClaimPreimage == "" {
+			htlcClaimCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.htlcClaim(*htlcClaimTxID, *htlcClaimVout, *htlcClaimPreimage, nodeID, *htlcClaimMine)
+	}
+
+	if htlcRefundCmd.Parsed() {
+		if *htlcRefundTxID == "" || *htlcRefundVout < 0 {
+			htlcRefundCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.htlcRefund(*htlcRefundTxID, *htlcRefundVout, nodeID, *htlcRefundMine)
 	}
 
 	if listAddressesCmd.Parsed() {
@@ -131,17 +399,119 @@ func (cli *CLI) Run() {
 		cli.printChain(nodeID)
 	}
 
+	if psbtCreateCmd.Parsed() {
+		if *psbtCreateHex == "" {
+			psbtCreateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtCreate(*psbtCreateHex, *psbtCreateChainID, nodeID)
+	}
+
+	if psbtUpdateCmd.Parsed() {
+		if *psbtUpdatePSBT == "" || (*psbtUpdatePrevOuts == "" && !*psbtUpdateChain) {
+			psbtUpdateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtUpdate(*psbtUpdatePSBT, *psbtUpdatePrevOuts, nodeID, *psbtUpdateChain)
+	}
+
+	if psbtSignCmd.Parsed() {
+		if *psbtSignPSBT == "" {
+			psbtSignCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtSign(*psbtSignPSBT, *psbtSignSigHash, nodeID)
+	}
+
+	if psbtCombineCmd.Parsed() {
+		if *psbtCombinePSBTs == "" {
+			psbtCombineCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtCombine(*psbtCombinePSBTs)
+	}
+
+	if psbtFinalizeCmd.Parsed() {
+		if *psbtFinalizePSBT == "" {
+			psbtFinalizeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtFinalize(*psbtFinalizePSBT)
+	}
+
+	if psbtExtractCmd.Parsed() {
+		if *psbtExtractPSBT == "" {
+			psbtExtractCmd.Usage()
+			os.Exit(1)
+		}
+		cli.psbtExtract(*psbtExtractPSBT)
+	}
+
 	if reindexUTXOCmd.Parsed() {
 		cli.reindexUTXO(nodeID)
 	}
 
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendLockTime < 0 || *sendFee < 0 {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		cli.send(*sendFrom, *sendTo, *sendAmount, *sendData, *sendLockTime, *sendCoins, *sendFee, nodeID, *sendMine)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || *sendManyFile == "" || *sendManyFee < 0 {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.sendMany(*sendManyFrom, *sendManyFile, *sendManyCoins, *sendManyFee, nodeID, *sendManyMine)
+	}
+
+	if sendMultisigCmd.Parsed() {
+		if *sendMultisigFrom == "" || *sendMultisigTo == "" || *sendMultisigAmount <= 0 || *sendMultisigSigners == "" {
+			sendMultisigCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.sendMultisig(*sendMultisigFrom, *sendMultisigTo, *sendMultisigAmount, *sendMultisigSigners, nodeID, *sendMultisigMine)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" || *createRawTxLockTime < 0 {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.createRawTransaction(*createRawTxInputs, *createRawTxOutputs, *createRawTxLockTime)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxHex == "" {
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.decodeRawTransaction(*decodeRawTxHex)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxHex == "" || *signRawTxPrevOuts == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.signRawTransaction(*signRawTxHex, *signRawTxPrevOuts, *signRawTxSigHash, *signRawTxChainID, nodeID)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxHex == "" {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.sendRawTransaction(*sendRawTxHex)
 	}
 
 	if startNodeCmd.Parsed() {
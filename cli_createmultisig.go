@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (cli *CLI) createMultisig(required int, keys string, p2sh bool, nodeID string) {
+	wallets, _ := NewWallets(nodeID)
+
+	var pubKeys [][]byte
+	for _, key := range strings.Split(keys, ",") {
+		key = strings.TrimSpace(key)
+
+		if wallet, ok := wallets.Wallets[key]; ok {
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err == nil {
+			_, err = parsePubKey(pubKey)
+		}
+		if err != nil {
+			log.Panicf("ERROR: %s is neither a wallet address nor a hex public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	if required < 1 || required > len(pubKeys) || len(pubKeys) > 16 {
+		log.Panic("ERROR: Multisig must require between 1 and N of at most 16 keys")
+	}
+	if !p2sh && len(pubKeys) > maxStandardMultisigKeys {
+		log.Panicf("ERROR: Bare multisig of more than %d keys is not relayed, set -p2sh", maxStandardMultisigKeys)
+	}
+
+	script := NewMultisigScript(required, pubKeys)
+	address := fmt.Sprintf("%s", MultisigAddress(script))
+
+	if p2sh {
+		if len(script) > maxScriptElementSize {
+			log.Panicf("ERROR: Redeem script is %d bytes long, maximum is %d", len(script), maxScriptElementSize)
+		}
+
+		address = wallets.AddRedeemScript(script)
+		wallets.SaveToFile(nodeID)
+	}
+
+	fmt.Printf("Your new %d-of-%d address: %s\n", required, len(pubKeys), address)
+}
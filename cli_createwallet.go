@@ -1,11 +1,25 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) createWallet(scheme string, nodeID string) {
+	keyScheme, err := ParseKeyScheme(scheme)
+	if err != nil {
+		log.Panic(err)
+	}
 
-func (cli *CLI) createWallet(nodeID string) {
 	wallets, _ := NewWallets(nodeID)
-	address := wallets.CreateWallet()
+	address := wallets.CreateWallet(keyScheme)
 	wallets.SaveToFile(nodeID)
 
+	wallet, err := wallets.GetWallet(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	fmt.Printf("Your new address: %s\n", address)
+	fmt.Printf("Public key: %x\n", wallet.PublicKey)
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+func (cli *CLI) findAnchor(data, nodeID string) {
+	payload, err := hex.DecodeString(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	anchors := bc.FindDataAnchors(payload)
+	if len(anchors) == 0 {
+		fmt.Println("Data is not anchored")
+		return
+	}
+
+	for _, anchor := range anchors {
+		fmt.Printf("Transaction %x output %d\n", anchor.TxID, anchor.Vout)
+		fmt.Printf("  Block %x at height %d, %s\n", anchor.Block.Hash, anchor.Block.Height, time.Unix(anchor.Block.Timestamp, 0).UTC())
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "fmt"
+
+func (cli *CLI) getChainID(nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	fmt.Printf("%x\n", bc.chainID)
+}
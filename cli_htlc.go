@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) htlcCreate(from, to string, amount Amount, secretHash string, timeout int64, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	if !ValidateAddress(to) {
+		log.Panic("ERROR: Recipient address is not valid")
+	}
+
+	var secret []byte
+	var hash []byte
+	if secretHash == "" {
+		secret = make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			log.Panic(err)
+		}
+		sum := sha256.Sum256(secret)
+		hash = sum[:]
+	} else {
+		var err error
+		hash, err = hex.DecodeString(secretHash)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, err := wallets.GetWallet(from)
+	exitOnError(err)
+
+	tx, err := NewHTLCTransaction(&wallet, to, amount, hash, timeout, &UTXOSet)
+	exitOnError(err)
+
+	cli.submitTransaction(bc, &UTXOSet, tx, from, mineNow)
+
+	fmt.Printf("HTLC output: %x:0\n", tx.ID)
+	fmt.Printf("Secret hash: %x\n", hash)
+	if secret != nil {
+		fmt.Printf("Secret: %x\n", secret)
+	}
+	fmt.Printf("Refundable after: %d\n", timeout)
+}
+
+func (cli *CLI) htlcClaim(txID string, vout int, preimage string, nodeID string, mineNow bool) {
+	prevTxID, err := hex.DecodeString(txID)
+	if err != nil {
+		log.Panic(err)
+	}
+	secret, err := hex.DecodeString(preimage)
+	if err != nil {
+		log.Panic(err)
+	}
+	if len(secret) > maxScriptElementSize {
+		exitOnError(fmt.Errorf("Preimage is %d bytes long, maximum is %d", len(secret), maxScriptElementSize))
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	out, err := findHTLCOutput(prevTxID, vout, &UTXOSet)
+	exitOnError(err)
+	_, recipientPubKeyHash, _, _ := extractHTLC(out.ScriptPubKey)
+	wallet := cli.htlcWallet(recipientPubKeyHash, nodeID)
+
+	tx, err := NewHTLCClaimTransaction(&wallet, prevTxID, vout, secret, &UTXOSet)
+	exitOnError(err)
+
+	cli.submitTransaction(bc, &UTXOSet, tx, fmt.Sprintf("%s", wallet.GetAddress()), mineNow)
+
+	fmt.Println("Success!")
+}
+
+func (cli *CLI) htlcRefund(txID string, vout int, nodeID string, mineNow bool) {
+	prevTxID, err := hex.DecodeString(txID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	out, err := findHTLCOutput(prevTxID, vout, &UTXOSet)
+	exitOnError(err)
+	_, _, senderPubKeyHash, _ := extractHTLC(out.ScriptPubKey)
+	wallet := cli.htlcWallet(senderPubKeyHash, nodeID)
+
+	tx, err := NewHTLCRefundTransaction(&wallet, prevTxID, vout, &UTXOSet)
+	exitOnError(err)
+
+	cli.submitTransaction(bc, &UTXOSet, tx, fmt.Sprintf("%s", wallet.GetAddress()), mineNow)
+
+	fmt.Println("Success!")
+}
+
+// htlcWallet returns the wallet whose public key hash is named in an HTLC
+func (cli *CLI) htlcWallet(pubKeyHash []byte, nodeID string) Wallet {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallet, ok := wallets.GetWalletByPubKeyHash(pubKeyHash)
+	if !ok {
+		log.Panicf("ERROR: Wallet with public key hash %x is not in the wallet file", pubKeyHash)
+	}
+
+	return wallet
+}
+
+// submitTransaction mines tx on this node with the transactions of its mempool, rewarding
+// minerAddress, or sends it to the central node and adds it to the mempool, so that its
+// outputs can be spent before it is mined
+func (cli *CLI) submitTransaction(bc *Blockchain, UTXOSet *UTXOSet, tx *Transaction, minerAddress string, mineNow bool) {
+	mempool := Mempool{bc}
+
+	if mineNow {
+		cbTx := NewCoinbaseTX(minerAddress, "")
+		_, pending, err := mempool.View()
+		exitOnError(err)
+		txs := NewBlockTemplate(cbTx, append(pending, tx))
+
+		newBlock, err := bc.MineBlock(txs)
+		exitOnError(err)
+		UTXOSet.Update(newBlock)
+		mempool.Revalidate()
+	} else {
+		sendTx(knownNodes[0], tx)
+		mempool.Add(tx)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (cli *CLI) psbtCreate(rawTx, chainID, nodeID string) {
+	tx, err := parseRawTransaction(rawTx)
+	exitOnError(err)
+
+	p, err := NewPSBT(tx, parseChainID(chainID, nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	printPSBT(p)
+}
+
+func (cli *CLI) psbtUpdate(psbt, prevOuts, nodeID string, useChain bool) {
+	p := parsePSBT(psbt)
+
+	outputs := make(map[string]TXOutput)
+	if prevOuts != "" {
+		var err error
+		outputs, err = parsePrevOuts(prevOuts)
+		exitOnError(err)
+	}
+
+	if useChain {
+		bc := NewBlockchain(nodeID)
+		UTXOSet := UTXOSet{bc}
+		defer bc.db.Close()
+
+		for _, vin := range p.Tx.Vin {
+			key := outpoint(vin.Txid, vin.Vout)
+			if _, ok := outputs[key]; ok {
+				continue
+			}
+			if out, err := UTXOSet.FindOutput(vin.Txid, vin.Vout); err == nil {
+				outputs[key] = out
+			}
+		}
+	}
+
+	wallets, _ := NewWallets(nodeID)
+	p.Update(outputs, wallets)
+
+	printPSBT(p)
+}
+
+func (cli *CLI) psbtSign(psbt, sigHash, nodeID string) {
+	p := parsePSBT(psbt)
+
+	hashType, err := ParseSigHashType(sigHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	signed, err := p.Sign(wallets, hashType)
+	exitOnError(err)
+
+	printPSBT(p)
+	fmt.Printf("Signatures added: %d\n", signed)
+}
+
+func (cli *CLI) psbtCombine(psbts string) {
+	var combined *PSBT
+
+	for _, psbt := range strings.Split(psbts, ",") {
+		p := parsePSBT(psbt)
+		if combined == nil {
+			combined = p
+			continue
+		}
+
+		if err := combined.Combine(p); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	printPSBT(combined)
+}
+
+func (cli *CLI) psbtFinalize(psbt string) {
+	p := parsePSBT(psbt)
+
+	complete := p.Finalize()
+
+	printPSBT(p)
+	fmt.Printf("Complete: %t\n", complete)
+}
+
+func (cli *CLI) psbtExtract(psbt string) {
+	p := parsePSBT(psbt)
+
+	tx, err := p.Extract()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("%x\n", tx.SerializeCanonical())
+}
+
+// printPSBT prints a base64-encoded PSBT
+func printPSBT(p *PSBT) {
+	fmt.Println(base64.StdEncoding.EncodeToString(p.Serialize()))
+}
+
+// parsePSBT decodes a base64-encoded PSBT
+func parsePSBT(psbt string) *PSBT {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(psbt))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	p, err := DeserializePSBT(data)
+	if err != nil {
+		log.Panicf("ERROR: Invalid PSBT: %s", err)
+	}
+
+	return p
+}
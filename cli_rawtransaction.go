@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+func (cli *CLI) createRawTransaction(inputs, outputs string, lockTime int64) {
+	var tx Transaction
+
+	for _, input := range strings.Split(inputs, ",") {
+		fields := strings.Split(strings.TrimSpace(input), ":")
+		if len(fields) != 2 && len(fields) != 3 {
+			exitOnError(fmt.Errorf("Input %q is not TXID:VOUT[:SEQUENCE]", input))
+		}
+
+		txID, vout, err := parseOutpoint(fields[0], fields[1])
+		exitOnError(err)
+		sequence := uint64(sequenceFinal)
+		if len(fields) == 3 {
+			sequence, err = strconv.ParseUint(fields[2], 0, 32)
+			if err != nil {
+				exitOnError(fmt.Errorf("Invalid sequence %q", fields[2]))
+			}
+		}
+
+		tx.Vin = append(tx.Vin, TXInput{txID, vout, nil, uint32(sequence)})
+	}
+
+	for _, output := range strings.Split(outputs, ",") {
+		fields := strings.Split(strings.TrimSpace(output), ":")
+		if len(fields) != 2 {
+			exitOnError(fmt.Errorf("Output %q is not ADDRESS:AMOUNT", output))
+		}
+		if !ValidateAddress(fields[0]) {
+			exitOnError(fmt.Errorf("Address %s is not valid", fields[0]))
+		}
+		amount, err := ParseAmount(fields[1])
+		if err != nil || amount <= 0 {
+			exitOnError(fmt.Errorf("Invalid amount %q", fields[1]))
+		}
+
+		tx.Vout = append(tx.Vout, *NewTXOutput(amount, fields[0]))
+	}
+
+	tx.LockTime = lockTime
+	tx.ID = tx.Hash()
+
+	fmt.Printf("%x\n", tx.SerializeCanonical())
+}
+
+func (cli *CLI) decodeRawTransaction(rawTx string) {
+	tx, err := parseRawTransaction(rawTx)
+	exitOnError(err)
+
+	fmt.Println(tx)
+	fmt.Printf("     Witness hash: %x\n", tx.WitnessHash())
+	fmt.Printf("     Size: %d bytes\n", tx.Size())
+}
+
+func (cli *CLI) signRawTransaction(rawTx, prevOuts, sigHash, chainID string, nodeID string) {
+	tx, err := parseRawTransaction(rawTx)
+	exitOnError(err)
+
+	hashType, err := ParseSigHashType(sigHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	outputs, err := parsePrevOuts(prevOuts)
+	exitOnError(err)
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	complete, err := tx.SignRaw(wallets, outputs, hashType, parseChainID(chainID, nodeID))
+	exitOnError(err)
+
+	fmt.Printf("%x\n", tx.SerializeCanonical())
+	fmt.Printf("Complete: %t\n", complete)
+}
+
+func (cli *CLI) sendRawTransaction(rawTx string) {
+	tx, err := parseRawTransaction(rawTx)
+	exitOnError(err)
+
+	sendTx(knownNodes[0], &tx)
+
+	fmt.Printf("%x\n", tx.ID)
+}
+
+// parseRawTransaction decodes a hex-encoded canonical transaction
+func parseRawTransaction(rawTx string) (Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(rawTx))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Invalid raw transaction: %s", err)
+	}
+
+	tx, err := DeserializeCanonical(data)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Invalid raw transaction: %s", err)
+	}
+
+	return tx, nil
+}
+
+// parsePrevOuts parses comma-separated TXID:VOUT:SCRIPT:AMOUNT previous outputs, where
+// SCRIPT is an address or a hex locking script
+func parsePrevOuts(prevOuts string) (map[string]TXOutput, error) {
+	outputs := make(map[string]TXOutput)
+
+	for _, prevOut := range strings.Split(prevOuts, ",") {
+		fields := strings.Split(strings.TrimSpace(prevOut), ":")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Previous output %q is not TXID:VOUT:SCRIPT:AMOUNT", prevOut)
+		}
+
+		txID, vout, err := parseOutpoint(fields[0], fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		var script []byte
+		if ValidateAddress(fields[2]) {
+			script = lockingScript([]byte(fields[2]))
+		} else if script, err = hex.DecodeString(fields[2]); err != nil {
+			return nil, fmt.Errorf("%q is neither an address nor a hex script", fields[2])
+		}
+
+		amount, err := ParseAmount(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid amount %q", fields[3])
+		}
+
+		outputs[outpoint(txID, vout)] = TXOutput{amount, script}
+	}
+
+	return outputs, nil
+}
+
+// parseOutpoint parses the transaction ID and output index of an outpoint
+func parseOutpoint(txid, vout string) ([]byte, int, error) {
+	txID, err := hex.DecodeString(txid)
+	if err != nil || len(txID) == 0 {
+		return nil, 0, fmt.Errorf("Invalid transaction ID %q", txid)
+	}
+
+	outIdx, err := strconv.Atoi(vout)
+	if err != nil || outIdx < 0 {
+		return nil, 0, fmt.Errorf("Invalid output index %q", vout)
+	}
+
+	return txID, outIdx, nil
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
 )
 
-func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
+func (cli *CLI) send(from, to string, amount Amount, data string, lockTime int64, coins string, feePerInput Amount, nodeID string, mineNow bool) {
 	if !ValidateAddress(from) {
 		log.Panic("ERROR: Sender address is not valid")
 	}
@@ -13,6 +14,19 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 		log.Panic("ERROR: Recipient address is not valid")
 	}
 
+	payload, err := hex.DecodeString(data)
+	if err != nil {
+		log.Panic(err)
+	}
+	if len(payload) > maxDataCarrierSize {
+		log.Panicf("ERROR: Data must be at most %d bytes", maxDataCarrierSize)
+	}
+
+	selector, err := ParseCoinSelector(coins)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	bc := NewBlockchain(nodeID)
 	UTXOSet := UTXOSet{bc}
 	defer bc.db.Close()
@@ -21,19 +35,13 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
+	wallet, err := wallets.GetWallet(from)
+	exitOnError(err)
 
-	tx := NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	tx, err := NewUTXOTransaction(&wallet, to, amount, payload, lockTime, selector, feePerInput, &UTXOSet)
+	exitOnError(err)
 
-	if mineNow {
-		cbTx := NewCoinbaseTX(from, "")
-		txs := []*Transaction{cbTx, tx}
-
-		newBlock := bc.MineBlock(txs)
-		UTXOSet.Update(newBlock)
-	} else {
-		sendTx(knownNodes[0], tx)
-	}
+	cli.submitTransaction(bc, &UTXOSet, tx, from, mineNow)
 
 	fmt.Println("Success!")
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+func (cli *CLI) sendMany(from, file string, coins string, feePerInput Amount, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	payments, err := ParsePayments(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	selector, err := ParseCoinSelector(coins)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, err := wallets.GetWallet(from)
+	exitOnError(err)
+
+	tx, err := NewSendManyTransaction(&wallet, payments, selector, feePerInput, &UTXOSet)
+	exitOnError(err)
+
+	cli.submitTransaction(bc, &UTXOSet, tx, from, mineNow)
+
+	var total Amount
+	for _, payment := range payments {
+		total += payment.Amount
+	}
+	fmt.Printf("Sent %s coins to %d recipients in transaction %x\n", total, len(payments), tx.ID)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (cli *CLI) sendMultisig(from, to string, amount Amount, signers, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	if !ValidateAddress(to) {
+		log.Panic("ERROR: Recipient address is not valid")
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var signerWallets []Wallet
+	for _, signer := range strings.Split(signers, ",") {
+		signer = strings.TrimSpace(signer)
+		wallet, err := wallets.GetWallet(signer)
+		exitOnError(err)
+		signerWallets = append(signerWallets, wallet)
+	}
+
+	var redeemScript []byte
+	if addressVersion, _ := decodeAddress([]byte(from)); addressVersion == scriptHashVersion {
+		redeemScript = wallets.RedeemScripts[from]
+		if redeemScript == nil {
+			log.Panicf("ERROR: Redeem script of %s is not in the wallet file", from)
+		}
+	}
+
+	tx, err := NewMultisigTransaction(from, redeemScript, signerWallets, to, amount, &UTXOSet)
+	exitOnError(err)
+
+	cli.submitTransaction(bc, &UTXOSet, tx, fmt.Sprintf("%s", signerWallets[0].GetAddress()), mineNow)
+
+	fmt.Println("Success!")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// bnbMaxTries bounds the number of subsets branch-and-bound explores before giving up
+const bnbMaxTries = 100000
+
+// errNotEnoughFunds is returned by coin selectors when the coins can't pay for the amount and fee
+var errNotEnoughFunds = errors.New("Not enough funds")
+
+// Coin is an unspent output that can fund a transaction input
+type Coin struct {
+	TxID  []byte
+	Vout  int
+	Value Amount
+}
+
+// effectiveValue returns what the coin adds to a transaction once the fee of its input is paid
+func (coin Coin) effectiveValue(feePerInput Amount) Amount {
+	return coin.Value - feePerInput
+}
+
+// CoinSelector chooses the coins that fund a payment. Every chosen coin adds feePerInput
+// to the transaction fee, so the chosen coins must be worth at least amount plus that fee
+type CoinSelector interface {
+	Select(coins []Coin, amount, feePerInput Amount) ([]Coin, error)
+}
+
+var coinSelectors = map[string]CoinSelector{
+	"bnb":      BranchAndBound{},
+	"largest":  LargestFirst{},
+	"smallest": SmallestFirst{},
+	"privacy":  PrivacyAware{},
+}
+
+// defaultCoinSelector is used by transactions that don't choose a strategy
+var defaultCoinSelector CoinSelector = BranchAndBound{}
+
+// ParseCoinSelector returns the coin selection strategy with the given name
+func ParseCoinSelector(name string) (CoinSelector, error) {
+	selector, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown coin selection strategy %q", name)
+	}
+
+	return selector, nil
+}
+
+// economicalCoins returns the coins worth more than the fee of spending them,
+// sorted by value in descending order
+func economicalCoins(coins []Coin, feePerInput Amount) []Coin {
+	var economical []Coin
+
+	for _, coin := range coins {
+		if coin.effectiveValue(feePerInput) > 0 {
+			economical = append(economical, coin)
+		}
+	}
+
+	sort.SliceStable(economical, func(i, j int) bool {
+		return economical[i].Value > economical[j].Value
+	})
+
+	return economical
+}
+
+// accumulate takes coins in order until their effective value covers amount
+func accumulate(coins []Coin, amount, feePerInput Amount) ([]Coin, error) {
+	var selected []Coin
+	var accumulated Amount
+
+	for _, coin := range coins {
+		if accumulated >= amount {
+			break
+		}
+
+		selected = append(selected, coin)
+		accumulated += coin.effectiveValue(feePerInput)
+	}
+
+	if accumulated < amount {
+		return nil, errNotEnoughFunds
+	}
+
+	return selected, nil
+}
+
+// LargestFirst spends the largest coins first, which keeps the number of inputs low
+type LargestFirst struct{}
+
+// Select implements CoinSelector
+func (LargestFirst) Select(coins []Coin, amount, feePerInput Amount) ([]Coin, error) {
+	return accumulate(economicalCoins(coins, feePerInput), amount, feePerInput)
+}
+
+// SmallestFirst spends the smallest coins first, which consolidates them into change.
+// Coins worth less than the fee of spending them are left alone
+type SmallestFirst struct{}
+
+// Select implements CoinSelector
+func (SmallestFirst) Select(coins []Coin, amount, feePerInput Amount) ([]Coin, error) {
+	economical := economicalCoins(coins, feePerInput)
+	for i, j := 0, len(economical)-1; i < j; i, j = i+1, j-1 {
+		economical[i], economical[j] = economical[j], economical[i]
+	}
+
+	return accumulate(economical, amount, feePerInput)
+}
+
+// BranchAndBound searches for coins whose effective value matches the amount exactly, so that
+// the transaction needs no change output. Up to feePerInput over the amount still counts as a
+// match: a change output worth less than that isn't worth spending later, so it's left as fee.
+// When there is no match, coins are selected largest first
+type BranchAndBound struct{}
+
+// Select implements CoinSelector
+func (BranchAndBound) Select(coins []Coin, amount, feePerInput Amount) ([]Coin, error) {
+	economical := economicalCoins(coins, feePerInput)
+
+	var remaining Amount
+	for _, coin := range economical {
+		remaining += coin.effectiveValue(feePerInput)
+	}
+	if remaining < amount {
+		return nil, errNotEnoughFunds
+	}
+
+	selected := make([]bool, len(economical))
+	tries := 0
+
+	var search func(i int, accumulated, remaining Amount) bool
+	search = func(i int, accumulated, remaining Amount) bool {
+		tries++
+		if tries > bnbMaxTries || accumulated > amount+feePerInput || accumulated+remaining < amount {
+			return false
+		}
+		if accumulated >= amount {
+			return true
+		}
+		if i == len(economical) {
+			return false
+		}
+
+		value := economical[i].effectiveValue(feePerInput)
+
+		selected[i] = true
+		if search(i+1, accumulated+value, remaining-value) {
+			return true
+		}
+		selected[i] = false
+
+		return search(i+1, accumulated, remaining-value)
+	}
+
+	if !search(0, 0, remaining) {
+		return LargestFirst{}.Select(coins, amount, feePerInput)
+	}
+
+	var match []Coin
+	for i, coin := range economical {
+		if selected[i] {
+			match = append(match, coin)
+		}
+	}
+
+	return match, nil
+}
+
+// PrivacyAware spends all outputs received in one transaction together, so that spends
+// don't link payments that arrived separately. It prefers the smallest transaction that
+// covers the amount on its own, then whole transactions largest first
+type PrivacyAware struct{}
+
+// Select implements CoinSelector
+func (PrivacyAware) Select(coins []Coin, amount, feePerInput Amount) ([]Coin, error) {
+	var groups [][]Coin
+	groupIndex := make(map[string]int)
+
+	for _, coin := range economicalCoins(coins, feePerInput) {
+		txID := hex.EncodeToString(coin.TxID)
+
+		i, ok := groupIndex[txID]
+		if !ok {
+			i = len(groups)
+			groupIndex[txID] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], coin)
+	}
+
+	groupValue := func(group []Coin) Amount {
+		var value Amount
+		for _, coin := range group {
+			value += coin.effectiveValue(feePerInput)
+		}
+		return value
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groupValue(groups[i]) > groupValue(groups[j])
+	})
+
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groupValue(groups[i]) >= amount {
+			return groups[i], nil
+		}
+	}
+
+	var selected []Coin
+	var accumulated Amount
+	for _, group := range groups {
+		if accumulated >= amount {
+			break
+		}
+
+		selected = append(selected, group...)
+		accumulated += groupValue(group)
+	}
+
+	if accumulated < amount {
+		return nil, errNotEnoughFunds
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func coinValues(coins []Coin) []int {
+	var values []int
+	for _, coin := range coins {
+		values = append(values, int(coin.Value))
+	}
+	return values
+}
+
+func TestCoinSelection(t *testing.T) {
+	coins := []Coin{
+		{[]byte("tx 1"), 0, 1},
+		{[]byte("tx 1"), 1, 7},
+		{[]byte("tx 2"), 0, 3},
+		{[]byte("tx 3"), 0, 20},
+		{[]byte("tx 4"), 0, 5},
+	}
+
+	selected, err := LargestFirst{}.Select(coins, 22, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{20, 7}, coinValues(selected))
+
+	selected, err = SmallestFirst{}.Select(coins, 8, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 3, 5}, coinValues(selected))
+
+	selected, err = SmallestFirst{}.Select(coins, 8, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 5, 7}, coinValues(selected), "Coins worth no more than their fee are skipped")
+
+	selected, err = BranchAndBound{}.Select(coins, 15, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 5, 3}, coinValues(selected), "An exact match needs no change")
+
+	selected, err = BranchAndBound{}.Select(coins, 12, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 5, 3}, coinValues(selected), "Up to one input's fee over the amount is a match")
+
+	selected, err = BranchAndBound{}.Select([]Coin{{[]byte("tx 1"), 0, 10}}, 4, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10}, coinValues(selected), "Without a match, coins are selected largest first")
+
+	selected, err = PrivacyAware{}.Select(coins, 6, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 1}, coinValues(selected), "Outputs of a transaction are spent together")
+
+	selected, err = PrivacyAware{}.Select(coins, 30, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{20, 7, 1, 5}, coinValues(selected))
+
+	for _, selector := range coinSelectors {
+		_, err = selector.Select(coins, 37, 0)
+		assert.Equal(t, errNotEnoughFunds, err)
+		_, err = selector.Select(coins, 30, 2)
+		assert.Equal(t, errNotEnoughFunds, err, "Fees count against the coins")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+// KeyScheme identifies the curve and signature algorithm of a key.
+// Public keys of every scheme but SchemeP256 start with the scheme byte, so that
+// a public key found in a script tells how to verify its signatures
+type KeyScheme byte
+
+// Key schemes
+const (
+	// SchemeP256 keys sign with ECDSA on NIST P-256. Their public keys are plain SEC1 points
+	SchemeP256 KeyScheme = 0x00
+	// SchemeSecp256k1 keys sign with ECDSA on secp256k1. Their public keys are SEC1 points
+	SchemeSecp256k1 KeyScheme = 0x11
+	// SchemeSchnorr keys sign with BIP340 Schnorr signatures on secp256k1. Their public keys
+	// are 32-byte x coordinates
+	SchemeSchnorr KeyScheme = 0x12
+)
+
+var keySchemeNames = map[KeyScheme]string{
+	SchemeP256:      "p256",
+	SchemeSecp256k1: "secp256k1",
+	SchemeSchnorr:   "schnorr",
+}
+
+// ParseKeyScheme returns the key scheme with the given name
+func ParseKeyScheme(name string) (KeyScheme, error) {
+	for scheme, schemeName := range keySchemeNames {
+		if schemeName == name {
+			return scheme, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown key scheme %q", name)
+}
+
+func (scheme KeyScheme) String() string {
+	return keySchemeNames[scheme]
+}
+
+// curve returns the elliptic curve of the scheme
+func (scheme KeyScheme) curve() elliptic.Curve {
+	if scheme == SchemeP256 {
+		return elliptic.P256()
+	}
+
+	return secp256k1()
+}
+
+// addressVersion returns the version byte of addresses paying to keys of the scheme
+func (scheme KeyScheme) addressVersion() byte {
+	switch scheme {
+	case SchemeSecp256k1:
+		return secp256k1Version
+	case SchemeSchnorr:
+		return schnorrVersion
+	default:
+		return version
+	}
+}
+
+// pubKeyScheme returns the scheme of a serialized public key
+func pubKeyScheme(pubKey []byte) KeyScheme {
+	if len(pubKey) > 0 {
+		switch KeyScheme(pubKey[0]) {
+		case SchemeSecp256k1, SchemeSchnorr:
+			return KeyScheme(pubKey[0])
+		}
+	}
+
+	return SchemeP256
+}
+
+// PrivateKey is a private key of any scheme
+type PrivateKey struct {
+	Scheme KeyScheme
+	D      []byte
+}
+
+// NewPrivateKey generates a random private key of the given scheme
+func NewPrivateKey(scheme KeyScheme) PrivateKey {
+	if _, ok := keySchemeNames[scheme]; !ok {
+		log.Panicf("ERROR: Unknown key scheme 0x%02x", byte(scheme))
+	}
+
+	n := scheme.curve().Params().N
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		log.Panic(err)
+	}
+	d.Add(d, big.NewInt(1))
+
+	return PrivateKey{scheme, d.FillBytes(make([]byte, 32))}
+}
+
+// PublicKey returns the serialized public key used in scripts
+func (key PrivateKey) PublicKey() []byte {
+	privKey := key.ecdsaKey()
+
+	switch key.Scheme {
+	case SchemeSchnorr:
+		return append([]byte{byte(SchemeSchnorr)}, privKey.X.FillBytes(make([]byte, 32))...)
+	case SchemeSecp256k1:
+		return append([]byte{byte(SchemeSecp256k1)}, serializePubKey(privKey.PublicKey)...)
+	default:
+		return serializePubKey(privKey.PublicKey)
+	}
+}
+
+// Sign signs a 32-byte digest with the scheme's algorithm. Signatures are deterministic
+func (key PrivateKey) Sign(digest []byte) []byte {
+	if key.Scheme == SchemeSchnorr {
+		return schnorrSign(new(big.Int).SetBytes(key.D), digest, make([]byte, 32))
+	}
+
+	return signDigest(key.ecdsaKey(), digest)
+}
+
+// ecdsaKey returns the key as an ECDSA key on the scheme's curve
+func (key PrivateKey) ecdsaKey() ecdsa.PrivateKey {
+	curve := key.Scheme.curve()
+	d := new(big.Int).SetBytes(key.D)
+	x, y := curve.ScalarBaseMult(key.D)
+
+	return ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySchemes(t *testing.T) {
+	digest := sha256.Sum256([]byte("data"))
+	other := sha256.Sum256([]byte("other data"))
+
+	for _, scheme := range []KeyScheme{SchemeP256, SchemeSecp256k1, SchemeSchnorr} {
+		privKey := NewPrivateKey(scheme)
+		pubKey := privKey.PublicKey()
+		assert.Equal(t, scheme, pubKeyScheme(pubKey), scheme.String())
+
+		signature := privKey.Sign(digest[:])
+		assert.True(t, verifySignature(pubKey, signature, digest[:]), scheme.String())
+		assert.False(t, verifySignature(pubKey, signature, other[:]), scheme.String())
+
+		wallet := NewWallet(scheme)
+		address := fmt.Sprintf("%s", wallet.GetAddress())
+		assert.True(t, ValidateAddress(address), scheme.String())
+		assert.Equal(t, NewP2PKHScript(HashPubKey(wallet.PublicKey)), lockingScript(wallet.GetAddress()), scheme.String())
+	}
+
+	schnorrKey := NewPrivateKey(SchemeSchnorr)
+	secpKey := PrivateKey{SchemeSecp256k1, schnorrKey.D}
+	assert.False(t, verifySignature(secpKey.PublicKey(), schnorrKey.Sign(digest[:]), digest[:]),
+		"Signatures only verify under their own scheme")
+}
+
+func TestWalletsFile(t *testing.T) {
+	chdirTemp(t)
+
+	wallets := Wallets{make(map[string]*Wallet), make(map[string][]byte)}
+	p256Address := wallets.CreateWallet(SchemeP256)
+	schnorrAddress := wallets.CreateWallet(SchemeSchnorr)
+	wallets.SaveToFile("test")
+
+	loaded, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{p256Address, schnorrAddress}, loaded.GetAddresses())
+	wallet, err := loaded.GetWallet(schnorrAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, *wallets.Wallets[schnorrAddress], wallet)
+	_, err = loaded.GetWallet(string(NewWallet(SchemeP256).GetAddress()))
+	assert.NotNil(t, err)
+
+	wallet, ok := loaded.GetWalletByPubKeyHash(HashPubKey(wallets.Wallets[p256Address].PublicKey))
+	assert.True(t, ok)
+	assert.Equal(t, p256Address, fmt.Sprintf("%s", wallet.GetAddress()))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+const mempoolBucket = "mempool"
+
+// Mempool holds the transactions waiting to be mined, kept in the blockchain database.
+// A node adds the transactions it accepts, a wallet the transactions it sends, so that
+// the outputs of unconfirmed transactions can be spent
+type Mempool struct {
+	Blockchain *Blockchain
+}
+
+// Add adds a transaction to the mempool
+func (m Mempool) Add(tx *Transaction) {
+	err := m.Blockchain.db.Update(func(dbTx *bolt.Tx) error {
+		b, err := dbTx.CreateBucketIfNotExists([]byte(mempoolBucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(tx.ID, tx.Serialize())
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Remove removes transactions from the mempool
+func (m Mempool) Remove(txIDs ...[]byte) {
+	err := m.Blockchain.db.Update(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(mempoolBucket))
+		if b == nil {
+			return nil
+		}
+
+		for _, txID := range txIDs {
+			if err := b.Delete(txID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// FindTransaction finds a mempool transaction by its ID
+func (m Mempool) FindTransaction(ID []byte) (Transaction, error) {
+	var txData []byte
+
+	err := m.Blockchain.db.View(func(dbTx *bolt.Tx) error {
+		if b := dbTx.Bucket([]byte(mempoolBucket)); b != nil {
+			txData = append([]byte{}, b.Get(ID)...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if len(txData) == 0 {
+		return Transaction{}, TxNotFoundError{ID}
+	}
+
+	return DeserializeTransaction(txData)
+}
+
+// Transactions returns the mempool transactions, each after the transactions it spends
+func (m Mempool) Transactions() []*Transaction {
+	txs, err := m.readTransactions()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return txs
+}
+
+// readTransactions reads the mempool transactions, each after the transactions it spends
+func (m Mempool) readTransactions() ([]*Transaction, error) {
+	var txs []*Transaction
+
+	err := m.Blockchain.db.View(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(mempoolBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			tx, err := DeserializeTransaction(v)
+			if err != nil {
+				return err
+			}
+			txs = append(txs, &tx)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortByDependency(txs), nil
+}
+
+// Count returns the number of transactions in the mempool
+func (m Mempool) Count() int {
+	return len(m.Transactions())
+}
+
+// View returns the UTXO set seen by a new transaction: the confirmed UTXO set with the
+// mempool transactions applied. It also returns the transactions applied, those still
+// spending unspent outputs, in dependency order
+func (m Mempool) View() (UTXOView, []*Transaction, error) {
+	view := newBlockView(UTXOSet{m.Blockchain})
+	var valid []*Transaction
+
+	txs, err := m.readTransactions()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tx := range txs {
+		if _, err := CheckTransactionInputs(tx, view); err != nil {
+			continue
+		}
+
+		view.apply(tx)
+		valid = append(valid, tx)
+	}
+
+	return view, valid, nil
+}
+
+// Revalidate removes the transactions that no longer spend unspent outputs: those mined
+// in a block or conflicting with one, and those spending their outputs
+func (m Mempool) Revalidate() {
+	_, valid, err := m.View()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	isValid := make(map[string]bool)
+	for _, tx := range valid {
+		isValid[hex.EncodeToString(tx.ID)] = true
+	}
+
+	var invalid [][]byte
+	for _, tx := range m.Transactions() {
+		if !isValid[hex.EncodeToString(tx.ID)] {
+			invalid = append(invalid, tx.ID)
+		}
+	}
+
+	m.Remove(invalid...)
+}
+
+// FindCoins finds the outputs locked with the script that are unspent once the mempool
+// transactions are applied, including the outputs of the mempool transactions
+func (m Mempool) FindCoins(lockingScript []byte) ([]Coin, error) {
+	view, txs, err := m.View()
+	if err != nil {
+		return nil, err
+	}
+	var coins []Coin
+
+	for _, coin := range (UTXOSet{m.Blockchain}).FindCoins(lockingScript) {
+		if _, err := view.FindOutput(coin.TxID, coin.Vout); err == nil {
+			coins = append(coins, coin)
+		}
+	}
+
+	for _, tx := range txs {
+		for outIdx, out := range tx.Vout {
+			if out.IsUnspendable() || !bytes.Equal(out.ScriptPubKey, lockingScript) {
+				continue
+			}
+			if _, err := view.FindOutput(tx.ID, outIdx); err == nil {
+				coins = append(coins, Coin{tx.ID, outIdx, out.Value})
+			}
+		}
+	}
+
+	return coins, nil
+}
+
+// Conflict returns the mempool transaction spending an output that tx spends, if any
+func (m Mempool) Conflict(tx *Transaction) *Transaction {
+	spent := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		spent[outpoint(vin.Txid, vin.Vout)] = true
+	}
+
+	for _, memTx := range m.Transactions() {
+		if bytes.Equal(memTx.ID, tx.ID) {
+			continue
+		}
+		for _, vin := range memTx.Vin {
+			if spent[outpoint(vin.Txid, vin.Vout)] {
+				return memTx
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortByDependency orders transactions so that each comes after the transactions it
+// spends outputs of, keeping the given order otherwise
+func sortByDependency(txs []*Transaction) []*Transaction {
+	byID := make(map[string]int)
+	for i := len(txs) - 1; i >= 0; i-- {
+		byID[hex.EncodeToString(txs[i].ID)] = i
+	}
+
+	var sorted []*Transaction
+	visited := make([]bool, len(txs))
+
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+
+		for _, vin := range txs[i].Vin {
+			if parent, ok := byID[hex.EncodeToString(vin.Txid)]; ok {
+				visit(parent)
+			}
+		}
+
+		sorted = append(sorted, txs[i])
+	}
+
+	for i := range txs {
+		visit(i)
+	}
+
+	return sorted
+}
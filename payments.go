@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Payment is an amount of coins to send to an address
+type Payment struct {
+	Address string `json:"address"`
+	Amount  Amount `json:"amount"`
+}
+
+// ParsePayments decodes a list of payments, either as a JSON array of
+// {"address": ..., "amount": ...} objects or as CSV rows of address and amount.
+// A CSV header row naming the columns is skipped
+func ParsePayments(data []byte) ([]Payment, error) {
+	var payments []Payment
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &payments); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(trimmed))
+		reader.FieldsPerRecord = 2
+		reader.TrimLeadingSpace = true
+
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if line == 1 && strings.EqualFold(record[0], "address") {
+				continue
+			}
+
+			amount, err := ParseAmount(record[1])
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: %s", line, err)
+			}
+			payments = append(payments, Payment{strings.TrimSpace(record[0]), amount})
+		}
+	}
+
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("No payments")
+	}
+
+	for i, payment := range payments {
+		if !ValidateAddress(payment.Address) {
+			return nil, fmt.Errorf("Payment %d: address %q is not valid", i+1, payment.Address)
+		}
+		if payment.Amount <= 0 {
+			return nil, fmt.Errorf("Payment %d: amount must be positive", i+1)
+		}
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePayments(t *testing.T) {
+	alice := fmt.Sprintf("%s", NewWallet(SchemeP256).GetAddress())
+	bob := fmt.Sprintf("%s", NewWallet(SchemeSchnorr).GetAddress())
+	expected := []Payment{{alice, 3 * coin}, {bob, coin / 2}}
+
+	payments, err := ParsePayments([]byte(fmt.Sprintf(`[{"address": %q, "amount": 3}, {"address": %q, "amount": 0.5}]`, alice, bob)))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, payments)
+
+	payments, err = ParsePayments([]byte(fmt.Sprintf("address,amount\n%s, 3\n%s,0.50\n", alice, bob)))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, payments)
+
+	_, err = ParsePayments([]byte(fmt.Sprintf("%s,three\n", alice)))
+	assert.NotNil(t, err)
+	_, err = ParsePayments([]byte(fmt.Sprintf("%s,0\n", alice)))
+	assert.NotNil(t, err, "Amounts must be positive")
+	_, err = ParsePayments([]byte("not an address,1\n"))
+	assert.NotNil(t, err)
+	_, err = ParsePayments([]byte("[]"))
+	assert.NotNil(t, err)
+}
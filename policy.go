@@ -0,0 +1,161 @@
+package main
+
+import "fmt"
+
+// Relay policy. It only decides which transactions this node accepts into its mempool,
+// relays and mines: a non-standard transaction is still valid in a block
+const (
+	// dustRelayFeeRate is the fee rate, in units per byte, at which spending an output
+	// worth less than its dust threshold costs more than the output is worth
+	dustRelayFeeRate Amount = 3
+	// spendInputSize is the canonical size of an input spending a P2PKH output: the
+	// outpoint, a script pushing a 64-byte signature with its hash type and a 33-byte
+	// compressed public key with its scheme prefix, and the sequence
+	spendInputSize = (1 + 32) + 4 + 1 + (1 + 64 + 1) + (1 + 1 + 33) + 4
+	// maxStandardTxSize is the largest canonical size of a transaction, in bytes
+	maxStandardTxSize    = 100000
+	maxStandardTxInputs  = 500
+	maxStandardTxOutputs = 500
+	// maxStandardScriptSigSize fits a P2SH input spending a 15-key multisig script
+	maxStandardScriptSigSize = 1650
+	// maxStandardMultisigKeys bounds bare multisig outputs, which burden the UTXO set
+	// with every key. Larger multisig should be wrapped in P2SH
+	maxStandardMultisigKeys = 3
+)
+
+// OutputType is the kind of an output's locking script
+type OutputType int
+
+// Output types
+const (
+	NonStandardOutput OutputType = iota
+	P2PKHOutput
+	P2SHOutput
+	MultisigOutput
+	HTLCOutput
+	DataCarrierOutput
+)
+
+var outputTypeNames = map[OutputType]string{
+	NonStandardOutput: "nonstandard",
+	P2PKHOutput:       "pubkeyhash",
+	P2SHOutput:        "scripthash",
+	MultisigOutput:    "multisig",
+	HTLCOutput:        "htlc",
+	DataCarrierOutput: "nulldata",
+}
+
+func (outputType OutputType) String() string {
+	return outputTypeNames[outputType]
+}
+
+// standardOutputTypes lists the output types this node relays
+var standardOutputTypes = map[OutputType]bool{
+	P2PKHOutput:       true,
+	P2SHOutput:        true,
+	MultisigOutput:    true,
+	HTLCOutput:        true,
+	DataCarrierOutput: true,
+}
+
+// ClassifyOutput returns the type of a locking script
+func ClassifyOutput(script []byte) OutputType {
+	switch {
+	case extractPubKeyHash(script) != nil:
+		return P2PKHOutput
+	case extractScriptHash(script) != nil:
+		return P2SHOutput
+	case extractDataCarrier(script) != nil:
+		return DataCarrierOutput
+	}
+
+	if m, pubKeys := extractMultisig(script); m > 0 {
+		if len(pubKeys) > maxStandardMultisigKeys {
+			return NonStandardOutput
+		}
+		return MultisigOutput
+	}
+
+	if secretHash, _, _, _ := extractHTLC(script); secretHash != nil {
+		return HTLCOutput
+	}
+
+	return NonStandardOutput
+}
+
+// PolicyError is the reason a transaction is rejected by the relay policy
+type PolicyError struct {
+	Reason string
+	Detail string
+}
+
+func (e PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+func policyError(reason, format string, a ...interface{}) error {
+	return PolicyError{reason, fmt.Sprintf(format, a...)}
+}
+
+// CheckStandard checks a transaction against the relay policy and returns the reason it's
+// rejected, or nil when it's standard
+func CheckStandard(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return policyError("coinbase", "coinbase transactions are only valid in blocks")
+	}
+
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return policyError("empty", "transaction needs inputs and outputs")
+	}
+	if len(tx.Vin) > maxStandardTxInputs {
+		return policyError("too-many-inputs", "%d inputs, at most %d allowed", len(tx.Vin), maxStandardTxInputs)
+	}
+	if len(tx.Vout) > maxStandardTxOutputs {
+		return policyError("too-many-outputs", "%d outputs, at most %d allowed", len(tx.Vout), maxStandardTxOutputs)
+	}
+	if size := tx.Size(); size > maxStandardTxSize {
+		return policyError("tx-size", "%d bytes, at most %d allowed", size, maxStandardTxSize)
+	}
+
+	for i, vin := range tx.Vin {
+		if len(vin.ScriptSig) > maxStandardScriptSigSize {
+			return policyError("scriptsig-size", "input %d script is %d bytes", i, len(vin.ScriptSig))
+		}
+		if !isPushOnly(vin.ScriptSig) {
+			return policyError("scriptsig-not-pushonly", "input %d script does more than push data", i)
+		}
+	}
+
+	dataCarriers := 0
+	for i, out := range tx.Vout {
+		outputType := ClassifyOutput(out.ScriptPubKey)
+		if !standardOutputTypes[outputType] {
+			return policyError("scriptpubkey", "output %d has a %s locking script", i, outputType)
+		}
+
+		if outputType == DataCarrierOutput {
+			dataCarriers++
+			if !out.IsValidDataCarrier() {
+				return policyError("datacarrier", "output %d carries value or more than %d bytes", i, maxDataCarrierSize)
+			}
+			continue
+		}
+
+		if threshold := dustThreshold(out); out.Value < threshold {
+			return policyError("dust", "output %d is worth %s, less than the %s it costs to spend", i, out.Value, threshold)
+		}
+	}
+	if dataCarriers > 1 {
+		return policyError("multi-op-return", "%d data-carrier outputs, at most 1 allowed", dataCarriers)
+	}
+
+	return nil
+}
+
+// dustThreshold returns the smallest value of a spendable output: the fee to pay for the
+// output and for the input spending it, at dustRelayFeeRate
+func dustThreshold(out TXOutput) Amount {
+	size := 8 + varIntSize(uint64(len(out.ScriptPubKey))) + len(out.ScriptPubKey) + spendInputSize
+
+	return Amount(size) * dustRelayFeeRate
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	pubKey := NewPrivateKey(SchemeP256).PublicKey()
+	pubKeyHash := HashPubKey(pubKey)
+
+	assert.Equal(t, P2PKHOutput, ClassifyOutput(NewP2PKHScript(pubKeyHash)))
+	assert.Equal(t, P2SHOutput, ClassifyOutput(NewP2SHScript(pubKeyHash)))
+	assert.Equal(t, MultisigOutput, ClassifyOutput(NewMultisigScript(1, [][]byte{pubKey, pubKey})))
+	assert.Equal(t, NonStandardOutput, ClassifyOutput(NewMultisigScript(1, [][]byte{pubKey, pubKey, pubKey, pubKey})))
+	assert.Equal(t, HTLCOutput, ClassifyOutput(NewHTLCScript(make([]byte, 32), pubKeyHash, pubKeyHash, 10)))
+	assert.Equal(t, DataCarrierOutput, ClassifyOutput(NewDataCarrierScript([]byte("data"))))
+	assert.Equal(t, NonStandardOutput, ClassifyOutput([]byte{Op1}))
+}
+
+func TestCheckStandard(t *testing.T) {
+	script := NewP2PKHScript(HashPubKey(NewPrivateKey(SchemeP256).PublicKey()))
+	newTx := func() *Transaction {
+		return &Transaction{
+			Vin:  []TXInput{{[]byte("tx 1"), 0, pushData([]byte("signature")), sequenceFinal}},
+			Vout: []TXOutput{{5 * coin, script}},
+		}
+	}
+	reason := func(tx *Transaction) string {
+		err := CheckStandard(tx)
+		if err == nil {
+			return ""
+		}
+		return err.(PolicyError).Reason
+	}
+
+	assert.Equal(t, "", reason(newTx()))
+
+	tx := newTx()
+	assert.Equal(t, Amount(531), dustThreshold(tx.Vout[0]), "A P2PKH output and the input spending it take 177 bytes")
+	tx.Vout[0].Value = dustThreshold(tx.Vout[0]) - 1
+	assert.Equal(t, "dust", reason(tx))
+	tx.Vout[0].Value = dustThreshold(tx.Vout[0])
+	assert.Equal(t, "", reason(tx))
+
+	tx = newTx()
+	tx.Vout = append(tx.Vout, *NewDataCarrierOutput([]byte("a")), *NewDataCarrierOutput([]byte("b")))
+	assert.Equal(t, "multi-op-return", reason(tx))
+
+	tx = newTx()
+	tx.Vout[0].ScriptPubKey = []byte{Op1}
+	assert.Equal(t, "scriptpubkey", reason(tx))
+
+	tx = newTx()
+	tx.Vin[0].ScriptSig = append(tx.Vin[0].ScriptSig, OpDup)
+	assert.Equal(t, "scriptsig-not-pushonly", reason(tx))
+
+	tx = newTx()
+	for len(tx.Vin) <= maxStandardTxInputs {
+		tx.Vin = append(tx.Vin, tx.Vin[0])
+	}
+	assert.Equal(t, "too-many-inputs", reason(tx))
+
+	tx = newTx()
+	tx.Vin[0].ScriptSig = bytes.Repeat([]byte{Op1}, maxStandardTxSize)
+	assert.Equal(t, "tx-size", reason(tx))
+
+	assert.Equal(t, "coinbase", reason(NewCoinbaseTX(string(encodeAddress(version, make([]byte, 20))), "data")))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// signRFC6979 computes an ECDSA signature of hash with the deterministic nonce of RFC 6979
+func signRFC6979(privKey ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int) {
+	curve := privKey.Curve
+	n := curve.Params().N
+	e := hashToInt(hash, n)
+	nextNonce := nonceRFC6979(curve, privKey.D, hash)
+
+	for {
+		k := nextNonce()
+
+		x, _ := curve.ScalarBaseMult(k.FillBytes(make([]byte, (n.BitLen()+7)/8)))
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, privKey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return r, s
+	}
+}
+
+// nonceRFC6979 returns a generator of the deterministic ECDSA nonces of RFC 6979, section 3.2,
+// for signing hash with the private key x, using HMAC-SHA256. Every call returns the next candidate,
+// which is only needed in the unlikely case that a nonce yields a zero r or s
+func nonceRFC6979(curve elliptic.Curve, x *big.Int, hash []byte) func() *big.Int {
+	q := curve.Params().N
+	qlen := q.BitLen()
+	rolen := (qlen + 7) / 8
+
+	int2octets := func(v *big.Int) []byte {
+		out := make([]byte, rolen)
+		return v.FillBytes(out)
+	}
+	bits2octets := func(b []byte) []byte {
+		z := hashToInt(b, q)
+		if z.Cmp(q) >= 0 {
+			z.Sub(z, q)
+		}
+		return int2octets(z)
+	}
+	mac := func(key []byte, data ...[]byte) []byte {
+		h := hmac.New(sha256.New, key)
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+
+	v := make([]byte, sha256.Size)
+	k := make([]byte, sha256.Size)
+	for i := range v {
+		v[i] = 0x01
+	}
+
+	privOctets := int2octets(x)
+	hashOctets := bits2octets(hash)
+
+	k = mac(k, v, []byte{0x00}, privOctets, hashOctets)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, privOctets, hashOctets)
+	v = mac(k, v)
+
+	first := true
+
+	return func() *big.Int {
+		for {
+			if !first {
+				k = mac(k, v, []byte{0x00})
+				v = mac(k, v)
+			}
+			first = false
+
+			var t []byte
+			for len(t) < rolen {
+				v = mac(k, v)
+				t = append(t, v...)
+			}
+
+			nonce := hashToInt(t[:rolen], q)
+			if nonce.Sign() > 0 && nonce.Cmp(q) < 0 {
+				return nonce
+			}
+		}
+	}
+}
+
+// hashToInt converts a hash to an integer, keeping as many leftmost bits as the curve order n has
+func hashToInt(hash []byte, n *big.Int) *big.Int {
+	v := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - n.BitLen(); excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+
+	return v
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hexInt(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 16)
+	return v
+}
+
+// Test vectors from RFC 6979, appendix A.2.5: ECDSA, 256 bits (prime field), with SHA-256
+func TestRFC6979(t *testing.T) {
+	curve := elliptic.P256()
+	privKey := ecdsa.PrivateKey{D: hexInt("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")}
+	privKey.Curve = curve
+	privKey.X, privKey.Y = curve.ScalarBaseMult(privKey.D.Bytes())
+
+	assert.Equal(t, hexInt("60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"), privKey.X)
+
+	vectors := []struct {
+		message string
+		k       string
+		r       string
+		s       string
+	}{
+		{
+			"sample",
+			"A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			"EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			"F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			"test",
+			"D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			"F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			"019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	}
+
+	for _, v := range vectors {
+		hash := sha256.Sum256([]byte(v.message))
+
+		assert.Equal(t, hexInt(v.k), nonceRFC6979(curve, privKey.D, hash[:])(), fmt.Sprintf("k for %q", v.message))
+
+		r, s := signRFC6979(privKey, hash[:])
+		assert.Equal(t, hexInt(v.r), r, fmt.Sprintf("r for %q", v.message))
+		assert.Equal(t, hexInt(v.s), s, fmt.Sprintf("s for %q", v.message))
+		assert.True(t, ecdsa.Verify(&privKey.PublicKey, hash[:], r, s))
+	}
+}
+
+func TestSignDigestIsDeterministic(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	digest := sha256.Sum256([]byte("transaction"))
+
+	signature := privKey.Sign(digest[:])
+	assert.Equal(t, signature, privKey.Sign(digest[:]))
+	assert.True(t, verifySignature(privKey.PublicKey(), signature, digest[:]))
+
+	other := sha256.Sum256([]byte("other transaction"))
+	assert.NotEqual(t, signature, privKey.Sign(other[:]))
+}
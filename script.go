@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Script opcodes. Values follow Bitcoin's so that scripts are easy to read for anyone familiar with it
+const (
+	Op0                   = 0x00
+	OpPushData1           = 0x4c
+	OpPushData2           = 0x4d
+	OpPushData4           = 0x4e
+	Op1Negate             = 0x4f
+	Op1                   = 0x51
+	Op16                  = 0x60
+	OpNop                 = 0x61
+	OpIf                  = 0x63
+	OpNotIf               = 0x64
+	OpElse                = 0x67
+	OpEndIf               = 0x68
+	OpVerify              = 0x69
+	OpReturn              = 0x6a
+	OpDrop                = 0x75
+	OpDup                 = 0x76
+	OpOver                = 0x78
+	OpSwap                = 0x7c
+	OpSize                = 0x82
+	OpEqual               = 0x87
+	OpEqualVerify         = 0x88
+	OpNot                 = 0x91
+	OpAdd                 = 0x93
+	OpSub                 = 0x94
+	OpNumEqual            = 0x9c
+	OpNumEqualVerify      = 0x9d
+	OpLessThan            = 0x9f
+	OpGreaterThan         = 0xa0
+	OpLessThanOrEqual     = 0xa1
+	OpGreaterThanOrEqual  = 0xa2
+	OpMin                 = 0xa3
+	OpMax                 = 0xa4
+	OpWithin              = 0xa5
+	OpSHA256              = 0xa8
+	OpHash160             = 0xa9
+	OpCheckSig            = 0xac
+	OpCheckSigVerify      = 0xad
+	OpCheckMultiSig       = 0xae
+	OpCheckMultiSigVerify = 0xaf
+	OpCheckLockTimeVerify = 0xb1
+	OpCheckSequenceVerify = 0xb2
+)
+
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	OpPushData4:           "OP_PUSHDATA4",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpOver:                "OP_OVER",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpNot:                 "OP_NOT",
+	OpAdd:                 "OP_ADD",
+	OpSub:                 "OP_SUB",
+	OpNumEqual:            "OP_NUMEQUAL",
+	OpNumEqualVerify:      "OP_NUMEQUALVERIFY",
+	OpLessThan:            "OP_LESSTHAN",
+	OpGreaterThan:         "OP_GREATERTHAN",
+	OpLessThanOrEqual:     "OP_LESSTHANOREQUAL",
+	OpGreaterThanOrEqual:  "OP_GREATERTHANOREQUAL",
+	OpMin:                 "OP_MIN",
+	OpMax:                 "OP_MAX",
+	OpWithin:              "OP_WITHIN",
+	OpSHA256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
+
+// scriptOp is a single parsed script instruction
+type scriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+// isPush checks whether the instruction only pushes data onto the stack
+func (op scriptOp) isPush() bool {
+	return op.opcode <= Op16 && op.opcode != 0x50
+}
+
+// parseScript splits a script into instructions
+func parseScript(script []byte) ([]scriptOp, error) {
+	var ops []scriptOp
+
+	for i := 0; i < len(script); {
+		opcode := script[i]
+		i++
+
+		var dataLen int
+		switch {
+		case opcode > Op0 && opcode < OpPushData1:
+			dataLen = int(opcode)
+		case opcode == OpPushData1:
+			if i+1 > len(script) {
+				return nil, errors.New("script: truncated OP_PUSHDATA1")
+			}
+			dataLen = int(script[i])
+			i++
+		case opcode == OpPushData2:
+			if i+2 > len(script) {
+				return nil, errors.New("script: truncated OP_PUSHDATA2")
+			}
+			dataLen = int(binary.LittleEndian.Uint16(script[i : i+2]))
+			i += 2
+		case opcode == OpPushData4:
+			if i+4 > len(script) {
+				return nil, errors.New("script: truncated OP_PUSHDATA4")
+			}
+			dataLen = int(binary.LittleEndian.Uint32(script[i : i+4]))
+			i += 4
+		default:
+			ops = append(ops, scriptOp{opcode, nil})
+			continue
+		}
+
+		if dataLen > len(script)-i {
+			return nil, errors.New("script: push past end of script")
+		}
+		ops = append(ops, scriptOp{opcode, script[i : i+dataLen]})
+		i += dataLen
+	}
+
+	return ops, nil
+}
+
+// pushData returns a script fragment that pushes data onto the stack. The data can't be
+// longer than maxScriptElementSize, as scripts pushing more would fail
+func pushData(data []byte) []byte {
+	var script []byte
+
+	switch {
+	case len(data) > maxScriptElementSize:
+		log.Panicf("ERROR: Can't push %d bytes, maximum is %d", len(data), maxScriptElementSize)
+	case len(data) == 0:
+		return []byte{Op0}
+	case len(data) < OpPushData1:
+		script = append(script, byte(len(data)))
+	case len(data) <= 0xff:
+		script = append(script, OpPushData1, byte(len(data)))
+	default:
+		script = append(script, OpPushData2, byte(len(data)), byte(len(data)>>8))
+	}
+
+	return append(script, data...)
+}
+
+// pushInt returns a script fragment that pushes a number onto the stack
+func pushInt(n int64) []byte {
+	if n == 0 {
+		return []byte{Op0}
+	}
+	if n == -1 {
+		return []byte{Op1Negate}
+	}
+	if n >= 1 && n <= 16 {
+		return []byte{byte(Op1 - 1 + n)}
+	}
+
+	return pushData(scriptNumBytes(n))
+}
+
+// NewP2PKHScript returns a locking script paying to a public key hash
+func NewP2PKHScript(pubKeyHash []byte) []byte {
+	script := []byte{OpDup, OpHash160}
+	script = append(script, pushData(pubKeyHash)...)
+
+	return append(script, OpEqualVerify, OpCheckSig)
+}
+
+// NewP2PKHUnlockingScript returns an unlocking script spending a P2PKH output
+func NewP2PKHUnlockingScript(signature, pubKey []byte) []byte {
+	return append(pushData(signature), pushData(pubKey)...)
+}
+
+// extractPubKeyHash returns the public key hash of a P2PKH locking script, or nil
+func extractPubKeyHash(script []byte) []byte {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 5 {
+		return nil
+	}
+
+	if ops[0].opcode != OpDup || ops[1].opcode != OpHash160 || len(ops[2].data) != 20 ||
+		ops[3].opcode != OpEqualVerify || ops[4].opcode != OpCheckSig {
+		return nil
+	}
+
+	return ops[2].data
+}
+
+// NewP2SHScript returns a locking script paying to the hash of a redeem script
+func NewP2SHScript(scriptHash []byte) []byte {
+	script := []byte{OpHash160}
+	script = append(script, pushData(scriptHash)...)
+
+	return append(script, OpEqual)
+}
+
+// extractScriptHash returns the redeem script hash of a P2SH locking script, or nil
+func extractScriptHash(script []byte) []byte {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 3 {
+		return nil
+	}
+
+	if ops[0].opcode != OpHash160 || len(ops[1].data) != 20 || ops[2].opcode != OpEqual {
+		return nil
+	}
+
+	return ops[1].data
+}
+
+// NewMultisigScript returns a locking script that requires m signatures matching the given public keys
+func NewMultisigScript(m int, pubKeys [][]byte) []byte {
+	script := pushInt(int64(m))
+	for _, pubKey := range pubKeys {
+		script = append(script, pushData(pubKey)...)
+	}
+	script = append(script, pushInt(int64(len(pubKeys)))...)
+
+	return append(script, OpCheckMultiSig)
+}
+
+// NewMultisigUnlockingScript returns an unlocking script spending a multisig output.
+// Signatures must be in the same order as the public keys they match
+func NewMultisigUnlockingScript(signatures [][]byte) []byte {
+	var script []byte
+	for _, signature := range signatures {
+		script = append(script, pushData(signature)...)
+	}
+
+	return script
+}
+
+// extractMultisig returns the threshold and public keys of a multisig locking script
+func extractMultisig(script []byte) (int, [][]byte) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) < 4 {
+		return 0, nil
+	}
+
+	smallInt := func(op scriptOp) int {
+		if op.opcode < Op1 || op.opcode > Op16 {
+			return -1
+		}
+		return int(op.opcode - Op1 + 1)
+	}
+
+	m := smallInt(ops[0])
+	n := smallInt(ops[len(ops)-2])
+	if m < 1 || n < m || n != len(ops)-3 || ops[len(ops)-1].opcode != OpCheckMultiSig {
+		return 0, nil
+	}
+
+	var pubKeys [][]byte
+	for _, op := range ops[1 : len(ops)-2] {
+		if len(op.data) == 0 {
+			return 0, nil
+		}
+		pubKeys = append(pubKeys, op.data)
+	}
+
+	return m, pubKeys
+}
+
+// NewHTLCScript returns a hash time-locked locking script. The recipient can spend it by
+// revealing the preimage of secretHash, the sender can spend it once timeout has passed.
+// timeout is a block height, or a UNIX time when it is at least lockTimeThreshold
+func NewHTLCScript(secretHash, recipientPubKeyHash, senderPubKeyHash []byte, timeout int64) []byte {
+	script := []byte{OpIf, OpSHA256}
+	script = append(script, pushData(secretHash)...)
+	script = append(script, OpEqualVerify, OpDup, OpHash160)
+	script = append(script, pushData(recipientPubKeyHash)...)
+	script = append(script, OpElse)
+	script = append(script, pushInt(timeout)...)
+	script = append(script, OpCheckLockTimeVerify, OpDrop, OpDup, OpHash160)
+	script = append(script, pushData(senderPubKeyHash)...)
+
+	return append(script, OpEndIf, OpEqualVerify, OpCheckSig)
+}
+
+// NewHTLCClaimScript returns an unlocking script spending an HTLC output with the secret
+func NewHTLCClaimScript(signature, pubKey, preimage []byte) ([]byte, error) {
+	if len(preimage) > maxScriptElementSize {
+		return nil, fmt.Errorf("Preimage is %d bytes long, maximum is %d", len(preimage), maxScriptElementSize)
+	}
+
+	script := NewP2PKHUnlockingScript(signature, pubKey)
+	script = append(script, pushData(preimage)...)
+
+	return append(script, Op1), nil
+}
+
+// NewHTLCRefundScript returns an unlocking script spending an HTLC output after its timeout
+func NewHTLCRefundScript(signature, pubKey []byte) []byte {
+	return append(NewP2PKHUnlockingScript(signature, pubKey), Op0)
+}
+
+// extractHTLC returns the secret hash, public key hashes and timeout of an HTLC locking script.
+// secretHash is nil if the script is not an HTLC
+func extractHTLC(script []byte) (secretHash, recipientPubKeyHash, senderPubKeyHash []byte, timeout int64) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 17 {
+		return nil, nil, nil, 0
+	}
+
+	template := []byte{OpIf, OpSHA256, 0, OpEqualVerify, OpDup, OpHash160, 0, OpElse, 0,
+		OpCheckLockTimeVerify, OpDrop, OpDup, OpHash160, 0, OpEndIf, OpEqualVerify, OpCheckSig}
+	for i, opcode := range template {
+		if opcode != 0 && ops[i].opcode != opcode {
+			return nil, nil, nil, 0
+		}
+	}
+
+	if len(ops[2].data) != 32 || len(ops[6].data) != 20 || len(ops[13].data) != 20 {
+		return nil, nil, nil, 0
+	}
+
+	switch {
+	case ops[8].opcode >= Op1 && ops[8].opcode <= Op16:
+		timeout = int64(ops[8].opcode - Op1 + 1)
+	case len(ops[8].data) > 0:
+		timeout, err = scriptNumFromBytes(ops[8].data, 5)
+		if err != nil || timeout < 0 {
+			return nil, nil, nil, 0
+		}
+	default:
+		return nil, nil, nil, 0
+	}
+
+	return ops[2].data, ops[6].data, ops[13].data, timeout
+}
+
+// NewDataCarrierScript returns a provably unspendable locking script carrying data
+func NewDataCarrierScript(data []byte) []byte {
+	return append([]byte{OpReturn}, pushData(data)...)
+}
+
+// extractDataCarrier returns the data carried by a data-carrier locking script, or nil
+func extractDataCarrier(script []byte) []byte {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 2 || ops[0].opcode != OpReturn || len(ops[1].data) == 0 {
+		return nil
+	}
+
+	return ops[1].data
+}
+
+// lockingScript returns the locking script that pays to an address
+func lockingScript(address []byte) []byte {
+	addressVersion, payload := decodeAddress(address)
+
+	switch addressVersion {
+	case multisigVersion:
+		return payload
+	case scriptHashVersion:
+		return NewP2SHScript(payload)
+	default:
+		return NewP2PKHScript(payload)
+	}
+}
+
+// DisasmScript returns a human-readable representation of a script
+func DisasmScript(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return fmt.Sprintf("[invalid script %x]", script)
+	}
+
+	var parts []string
+	for _, op := range ops {
+		switch {
+		case op.data != nil:
+			parts = append(parts, hex.EncodeToString(op.data))
+		case op.opcode >= Op1 && op.opcode <= Op16:
+			parts = append(parts, fmt.Sprintf("OP_%d", op.opcode-Op1+1))
+		case opcodeNames[op.opcode] != "":
+			parts = append(parts, opcodeNames[op.opcode])
+		default:
+			parts = append(parts, fmt.Sprintf("OP_UNKNOWN_%x", op.opcode))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// scriptNumBytes encodes a number as a minimal little-endian sign-magnitude byte array
+func scriptNumBytes(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+
+	var result []byte
+	for n > 0 {
+		result = append(result, byte(n&0xff))
+		n >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+// scriptNumFromBytes decodes a number encoded by scriptNumBytes
+func scriptNumFromBytes(data []byte, maxLen int) (int64, error) {
+	if len(data) > maxLen {
+		return 0, fmt.Errorf("script: number is %d bytes long, maximum is %d", len(data), maxLen)
+	}
+	if len(data) > 0 && data[len(data)-1]&0x7f == 0 {
+		if len(data) == 1 || data[len(data)-2]&0x80 == 0 {
+			return 0, errors.New("script: number is not minimally encoded")
+		}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	var result int64
+	for i, b := range data {
+		result |= int64(b) << uint(8*i)
+	}
+
+	if data[len(data)-1]&0x80 != 0 {
+		result &= ^(int64(0x80) << uint(8*(len(data)-1)))
+		return -result, nil
+	}
+
+	return result, nil
+}
+
+// isTrue interprets a stack element as a boolean
+func isTrue(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			// negative zero is false
+			return !(i == len(data)-1 && b == 0x80)
+		}
+	}
+
+	return false
+}
+
+// isPushOnly checks whether a script consists only of data pushes
+func isPushOnly(script []byte) bool {
+	ops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		if !op.isPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scriptPushes returns the data pushed by a push-only script
+func scriptPushes(script []byte) [][]byte {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil
+	}
+
+	var pushes [][]byte
+	for _, op := range ops {
+		if !op.isPush() {
+			return nil
+		}
+		pushes = append(pushes, op.data)
+	}
+
+	return pushes
+}
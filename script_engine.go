@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const maxScriptSize = 10000
+const maxScriptElementSize = 520
+const maxOpsPerScript = 201
+const maxStackSize = 1000
+const maxScriptNumLen = 4
+const maxPubKeysPerMultisig = 20
+
+var (
+	errScriptFailed   = errors.New("script: evaluated to false")
+	errVerifyFailed   = errors.New("script: OP_VERIFY failed")
+	errStackUnderflow = errors.New("script: stack underflow")
+	errUnbalancedCond = errors.New("script: unbalanced conditional")
+)
+
+// scriptChecker checks signatures and lock times of the spending transaction on behalf of the script engine
+type scriptChecker interface {
+	CheckSig(signature, pubKey []byte) bool
+	CheckLockTime(lockTime int64) bool
+	CheckSequence(sequence int64) bool
+}
+
+// scriptEngine is a small, deterministic stack machine that evaluates scripts
+type scriptEngine struct {
+	stack     [][]byte
+	condStack []bool
+	opCount   int
+	checker   scriptChecker
+}
+
+// ExecuteScript runs an unlocking script followed by the locking script it spends
+// and, for P2SH outputs, the redeem script. It returns nil if the spend is authorized
+func ExecuteScript(unlockingScript, lockingScript []byte, checker scriptChecker) error {
+	if !isPushOnly(unlockingScript) {
+		return errors.New("script: unlocking script is not push-only")
+	}
+
+	vm := &scriptEngine{checker: checker}
+
+	err := vm.run(unlockingScript)
+	if err != nil {
+		return err
+	}
+
+	unlockingStack := append([][]byte{}, vm.stack...)
+
+	err = vm.run(lockingScript)
+	if err != nil {
+		return err
+	}
+
+	if len(vm.stack) == 0 || !isTrue(vm.stack[len(vm.stack)-1]) {
+		return errScriptFailed
+	}
+
+	// A P2SH output only commits to the hash of the redeem script, which is
+	// revealed as the last push of the unlocking script and must be satisfied too
+	if extractScriptHash(lockingScript) != nil {
+		redeemScript := unlockingStack[len(unlockingStack)-1]
+		vm.stack = unlockingStack[:len(unlockingStack)-1]
+
+		err = vm.run(redeemScript)
+		if err != nil {
+			return err
+		}
+
+		if len(vm.stack) == 0 || !isTrue(vm.stack[len(vm.stack)-1]) {
+			return errScriptFailed
+		}
+	}
+
+	return nil
+}
+
+// run executes a single script on the current stack
+func (vm *scriptEngine) run(script []byte) error {
+	if len(script) > maxScriptSize {
+		return fmt.Errorf("script: size %d exceeds maximum %d", len(script), maxScriptSize)
+	}
+
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	vm.opCount = 0
+	vm.condStack = nil
+
+	for _, op := range ops {
+		err := vm.step(op)
+		if err != nil {
+			return err
+		}
+
+		if len(vm.stack) > maxStackSize {
+			return fmt.Errorf("script: stack size exceeds maximum %d", maxStackSize)
+		}
+	}
+
+	if len(vm.condStack) != 0 {
+		return errUnbalancedCond
+	}
+
+	return nil
+}
+
+// executing reports whether the current branch is being executed
+func (vm *scriptEngine) executing() bool {
+	for _, cond := range vm.condStack {
+		if !cond {
+			return false
+		}
+	}
+
+	return true
+}
+
+// step executes a single instruction
+func (vm *scriptEngine) step(op scriptOp) error {
+	if len(op.data) > maxScriptElementSize {
+		return fmt.Errorf("script: element size %d exceeds maximum %d", len(op.data), maxScriptElementSize)
+	}
+
+	if op.opcode > Op16 {
+		vm.opCount++
+		if vm.opCount > maxOpsPerScript {
+			return fmt.Errorf("script: more than %d operations", maxOpsPerScript)
+		}
+	}
+
+	// Flow control is evaluated even inside a branch that is not executed
+	switch op.opcode {
+	case OpIf, OpNotIf:
+		cond := false
+		if vm.executing() {
+			data, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			cond = isTrue(data)
+			if op.opcode == OpNotIf {
+				cond = !cond
+			}
+		}
+		vm.condStack = append(vm.condStack, cond)
+		return nil
+	case OpElse:
+		if len(vm.condStack) == 0 {
+			return errUnbalancedCond
+		}
+		vm.condStack[len(vm.condStack)-1] = !vm.condStack[len(vm.condStack)-1]
+		return nil
+	case OpEndIf:
+		if len(vm.condStack) == 0 {
+			return errUnbalancedCond
+		}
+		vm.condStack = vm.condStack[:len(vm.condStack)-1]
+		return nil
+	}
+
+	if !vm.executing() {
+		return nil
+	}
+
+	if op.isPush() {
+		switch {
+		case op.opcode == Op1Negate:
+			vm.push(scriptNumBytes(-1))
+		case op.opcode >= Op1 && op.opcode <= Op16:
+			vm.push(scriptNumBytes(int64(op.opcode - Op1 + 1)))
+		default:
+			vm.push(op.data)
+		}
+		return nil
+	}
+
+	switch op.opcode {
+	case OpNop:
+	case OpVerify:
+		return vm.verify()
+	case OpReturn:
+		return errors.New("script: OP_RETURN executed")
+	case OpDrop:
+		_, err := vm.pop()
+		return err
+	case OpDup:
+		data, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(data)
+	case OpOver:
+		data, err := vm.peek(1)
+		if err != nil {
+			return err
+		}
+		vm.push(data)
+	case OpSwap:
+		if len(vm.stack) < 2 {
+			return errStackUnderflow
+		}
+		n := len(vm.stack)
+		vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+	case OpSize:
+		data, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(scriptNumBytes(int64(len(data))))
+	case OpEqual, OpEqualVerify:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(bytes.Equal(a, b))
+		if op.opcode == OpEqualVerify {
+			return vm.verify()
+		}
+	case OpNot:
+		n, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(n == 0)
+	case OpAdd, OpSub, OpNumEqual, OpNumEqualVerify, OpLessThan, OpGreaterThan,
+		OpLessThanOrEqual, OpGreaterThanOrEqual, OpMin, OpMax:
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.arith(op.opcode, a, b)
+	case OpWithin:
+		max, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		min, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		x, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(min <= x && x < max)
+	case OpSHA256:
+		data, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(data)
+		vm.push(hash[:])
+	case OpHash160:
+		data, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(HashPubKey(data))
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(vm.checker != nil && vm.checker.CheckSig(signature, pubKey))
+		if op.opcode == OpCheckSigVerify {
+			return vm.verify()
+		}
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		ok, err := vm.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(ok)
+		if op.opcode == OpCheckMultiSigVerify {
+			return vm.verify()
+		}
+	case OpCheckLockTimeVerify:
+		data, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := scriptNumFromBytes(data, 5)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return errors.New("script: negative lock time")
+		}
+		if vm.checker == nil || !vm.checker.CheckLockTime(lockTime) {
+			return errors.New("script: lock time not satisfied")
+		}
+	case OpCheckSequenceVerify:
+		data, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		sequence, err := scriptNumFromBytes(data, 5)
+		if err != nil {
+			return err
+		}
+		if sequence < 0 {
+			return errors.New("script: negative relative lock time")
+		}
+		if sequence&sequenceDisableFlag != 0 {
+			return nil
+		}
+		if vm.checker == nil || !vm.checker.CheckSequence(sequence) {
+			return errors.New("script: relative lock time not satisfied")
+		}
+	default:
+		return fmt.Errorf("script: unknown opcode 0x%02x", op.opcode)
+	}
+
+	return nil
+}
+
+// arith executes a binary numeric instruction
+func (vm *scriptEngine) arith(opcode byte, a, b int64) error {
+	switch opcode {
+	case OpAdd:
+		vm.push(scriptNumBytes(a + b))
+	case OpSub:
+		vm.push(scriptNumBytes(a - b))
+	case OpNumEqual, OpNumEqualVerify:
+		vm.pushBool(a == b)
+		if opcode == OpNumEqualVerify {
+			return vm.verify()
+		}
+	case OpLessThan:
+		vm.pushBool(a < b)
+	case OpGreaterThan:
+		vm.pushBool(a > b)
+	case OpLessThanOrEqual:
+		vm.pushBool(a <= b)
+	case OpGreaterThanOrEqual:
+		vm.pushBool(a >= b)
+	case OpMin:
+		if b < a {
+			a = b
+		}
+		vm.push(scriptNumBytes(a))
+	case OpMax:
+		if b > a {
+			a = b
+		}
+		vm.push(scriptNumBytes(a))
+	}
+
+	return nil
+}
+
+// checkMultiSig pops n public keys and m signatures and checks that every signature
+// matches one of the keys, in order
+func (vm *scriptEngine) checkMultiSig() (bool, error) {
+	n, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > maxPubKeysPerMultisig {
+		return false, fmt.Errorf("script: invalid public key count %d", n)
+	}
+
+	vm.opCount += int(n)
+	if vm.opCount > maxOpsPerScript {
+		return false, fmt.Errorf("script: more than %d operations", maxOpsPerScript)
+	}
+
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		pubKeys[i], err = vm.pop()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	m, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, fmt.Errorf("script: invalid signature count %d", m)
+	}
+
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		signatures[i], err = vm.pop()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if vm.checker == nil {
+		return m == 0, nil
+	}
+
+	sigIdx, keyIdx := 0, 0
+	for sigIdx < len(signatures) && len(signatures)-sigIdx <= len(pubKeys)-keyIdx {
+		if vm.checker.CheckSig(signatures[sigIdx], pubKeys[keyIdx]) {
+			sigIdx++
+		}
+		keyIdx++
+	}
+
+	return sigIdx == len(signatures), nil
+}
+
+func (vm *scriptEngine) push(data []byte) {
+	vm.stack = append(vm.stack, data)
+}
+
+func (vm *scriptEngine) pushBool(value bool) {
+	if value {
+		vm.push([]byte{1})
+	} else {
+		vm.push(nil)
+	}
+}
+
+func (vm *scriptEngine) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, errStackUnderflow
+	}
+
+	data := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return data, nil
+}
+
+func (vm *scriptEngine) popInt() (int64, error) {
+	data, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	return scriptNumFromBytes(data, maxScriptNumLen)
+}
+
+// peek returns the element at the given depth from the top of the stack
+func (vm *scriptEngine) peek(depth int) ([]byte, error) {
+	if len(vm.stack) <= depth {
+		return nil, errStackUnderflow
+	}
+
+	return vm.stack[len(vm.stack)-1-depth], nil
+}
+
+// verify pops the top element and fails unless it is true
+func (vm *scriptEngine) verify() error {
+	data, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if !isTrue(data) {
+		return errVerifyFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSigChecker struct {
+	signature []byte
+	pubKey    []byte
+}
+
+func (c fakeSigChecker) CheckSig(signature, pubKey []byte) bool {
+	return bytes.Equal(signature, c.signature) && bytes.Equal(pubKey, c.pubKey)
+}
+
+func (c fakeSigChecker) CheckLockTime(lockTime int64) bool {
+	return false
+}
+
+func (c fakeSigChecker) CheckSequence(sequence int64) bool {
+	return false
+}
+
+func TestScriptNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, -32768, 1 << 30} {
+		decoded, err := scriptNumFromBytes(scriptNumBytes(n), 8)
+		assert.Nil(t, err)
+		assert.Equal(t, n, decoded)
+	}
+
+	_, err := scriptNumFromBytes([]byte{0x01, 0x00}, 4)
+	assert.NotNil(t, err, "Non-minimal encoding is rejected")
+
+	_, err = scriptNumFromBytes([]byte{0x01, 0x02, 0x03, 0x04, 0x05}, 4)
+	assert.NotNil(t, err, "Oversized number is rejected")
+}
+
+func TestPushData(t *testing.T) {
+	for _, size := range []int{1, OpPushData1, 0xff + 1, maxScriptElementSize} {
+		data := bytes.Repeat([]byte{0x01}, size)
+		ops, err := parseScript(pushData(data))
+		assert.Nil(t, err)
+		assert.Equal(t, []scriptOp{{pushData(data)[0], data}}, ops, size)
+	}
+	assert.Panics(t, func() { pushData(make([]byte, maxScriptElementSize+1)) })
+
+	ops, err := parseScript([]byte{OpPushData4, 0x02, 0x00, 0x00, 0x00, 0xab, 0xcd})
+	assert.Nil(t, err)
+	assert.Equal(t, []scriptOp{{OpPushData4, []byte{0xab, 0xcd}}}, ops)
+	assert.Nil(t, ExecuteScript([]byte{OpPushData4, 0x01, 0x00, 0x00, 0x00, 0x01}, nil, nil), "OP_PUSHDATA4 pushes its data")
+
+	_, err = parseScript([]byte{OpPushData4, 0x01, 0x00})
+	assert.NotNil(t, err)
+	_, err = parseScript([]byte{OpPushData4, 0xff, 0xff, 0xff, 0xff, 0x01})
+	assert.NotNil(t, err)
+}
+
+func TestExecuteP2PKH(t *testing.T) {
+	pubKey := []byte("public key")
+	signature := []byte("signature")
+	checker := fakeSigChecker{signature, pubKey}
+
+	locking := NewP2PKHScript(HashPubKey(pubKey))
+	assert.Equal(t, HashPubKey(pubKey), extractPubKeyHash(locking))
+
+	err := ExecuteScript(NewP2PKHUnlockingScript(signature, pubKey), locking, checker)
+	assert.Nil(t, err, "Valid signature unlocks the output")
+
+	err = ExecuteScript(NewP2PKHUnlockingScript([]byte("forged"), pubKey), locking, checker)
+	assert.NotNil(t, err, "Invalid signature is rejected")
+
+	err = ExecuteScript(NewP2PKHUnlockingScript(signature, []byte("other key")), locking, checker)
+	assert.NotNil(t, err, "Wrong public key is rejected")
+}
+
+func TestExecuteArithmeticAndFlowControl(t *testing.T) {
+	// 2 3 ADD 5 NUMEQUAL IF 1 ELSE 0 ENDIF
+	script := []byte{Op1 + 1, Op1 + 2, OpAdd, Op1 + 4, OpNumEqual, OpIf, Op1, OpElse, Op0, OpEndIf}
+	assert.Nil(t, ExecuteScript(nil, script, nil))
+
+	// 4 3 ADD 5 NUMEQUAL IF 1 ELSE 0 ENDIF
+	script[0] = Op1 + 3
+	assert.Equal(t, errScriptFailed, ExecuteScript(nil, script, nil))
+
+	// 5 WITHIN(1, 10)
+	within := append(pushInt(5), append(pushInt(1), append(pushInt(10), OpWithin)...)...)
+	assert.Nil(t, ExecuteScript(nil, within, nil))
+}
+
+func TestExecuteHashLock(t *testing.T) {
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	locking := append([]byte{OpSHA256}, pushData(hash[:])...)
+	locking = append(locking, OpEqual)
+
+	assert.Nil(t, ExecuteScript(pushData(preimage), locking, nil))
+	assert.NotNil(t, ExecuteScript(pushData([]byte("guess")), locking, nil))
+}
+
+func TestExecuteRejectsMalformedScripts(t *testing.T) {
+	assert.Equal(t, errUnbalancedCond, ExecuteScript(nil, []byte{Op1, OpIf, Op1}, nil))
+	assert.Equal(t, errStackUnderflow, ExecuteScript(nil, []byte{OpDup}, nil))
+	assert.NotNil(t, ExecuteScript([]byte{Op1, OpDup}, []byte{Op1}, nil), "Unlocking script must be push-only")
+	assert.NotNil(t, ExecuteScript(nil, []byte{Op1, OpReturn}, nil))
+	assert.NotNil(t, ExecuteScript(nil, []byte{0x05, 0x01}, nil), "Truncated push is rejected")
+
+	tooManyOps := []byte{Op1}
+	for i := 0; i <= maxOpsPerScript; i++ {
+		tooManyOps = append(tooManyOps, OpNop)
+	}
+	assert.NotNil(t, ExecuteScript(nil, tooManyOps, nil))
+}
+
+func TestExecuteMultisig(t *testing.T) {
+	pubKeys := [][]byte{[]byte("key a"), []byte("key b"), []byte("key c")}
+	locking := NewMultisigScript(2, pubKeys)
+
+	m, extracted := extractMultisig(locking)
+	assert.Equal(t, 2, m)
+	assert.Equal(t, pubKeys, extracted)
+
+	checker := multiSigChecker{"key a": []byte("sig a"), "key c": []byte("sig c")}
+
+	err := ExecuteScript(NewMultisigUnlockingScript([][]byte{[]byte("sig a"), []byte("sig c")}), locking, checker)
+	assert.Nil(t, err, "2 of 3 signatures unlock the output")
+
+	err = ExecuteScript(NewMultisigUnlockingScript([][]byte{[]byte("sig c"), []byte("sig a")}), locking, checker)
+	assert.NotNil(t, err, "Signatures out of key order are rejected")
+
+	err = ExecuteScript(NewMultisigUnlockingScript([][]byte{[]byte("sig a")}), locking, checker)
+	assert.NotNil(t, err, "Too few signatures are rejected")
+}
+
+type multiSigChecker map[string][]byte
+
+func (c multiSigChecker) CheckSig(signature, pubKey []byte) bool {
+	return bytes.Equal(c[string(pubKey)], signature)
+}
+
+func (c multiSigChecker) CheckLockTime(lockTime int64) bool {
+	return false
+}
+
+func (c multiSigChecker) CheckSequence(sequence int64) bool {
+	return false
+}
+
+func TestExecuteP2SH(t *testing.T) {
+	pubKey := []byte("public key")
+	signature := []byte("signature")
+	checker := fakeSigChecker{signature, pubKey}
+
+	redeemScript := NewP2PKHScript(HashPubKey(pubKey))
+	locking := NewP2SHScript(HashPubKey(redeemScript))
+	assert.Equal(t, HashPubKey(redeemScript), extractScriptHash(locking))
+
+	unlocking := append(NewP2PKHUnlockingScript(signature, pubKey), pushData(redeemScript)...)
+	assert.Nil(t, ExecuteScript(unlocking, locking, checker), "Revealed policy is satisfied")
+
+	unlocking = append(NewP2PKHUnlockingScript([]byte("forged"), pubKey), pushData(redeemScript)...)
+	assert.NotNil(t, ExecuteScript(unlocking, locking, checker), "Redeem script must be satisfied")
+
+	otherScript := []byte{Op1}
+	unlocking = pushData(otherScript)
+	assert.NotNil(t, ExecuteScript(unlocking, locking, checker), "Redeem script must match the hash")
+}
+
+type lockTimeChecker struct {
+	fakeSigChecker
+	lockTime int64
+}
+
+func (c lockTimeChecker) CheckLockTime(lockTime int64) bool {
+	return c.lockTime >= lockTime
+}
+
+func TestExecuteHTLC(t *testing.T) {
+	recipientKey, senderKey := []byte("recipient key"), []byte("sender key")
+	signature := []byte("signature")
+	secret := []byte("secret")
+	secretHash := sha256.Sum256(secret)
+
+	locking := NewHTLCScript(secretHash[:], HashPubKey(recipientKey), HashPubKey(senderKey), 100)
+	hash, recipient, sender, timeout := extractHTLC(locking)
+	assert.Equal(t, secretHash[:], hash)
+	assert.Equal(t, HashPubKey(recipientKey), recipient)
+	assert.Equal(t, HashPubKey(senderKey), sender)
+	assert.Equal(t, int64(100), timeout)
+
+	recipientChecker := lockTimeChecker{fakeSigChecker{signature, recipientKey}, 0}
+	claim, err := NewHTLCClaimScript(signature, recipientKey, secret)
+	assert.Nil(t, err)
+	err = ExecuteScript(claim, locking, recipientChecker)
+	assert.Nil(t, err, "Recipient claims with the secret")
+
+	claim, err = NewHTLCClaimScript(signature, recipientKey, []byte("guess"))
+	assert.Nil(t, err)
+	err = ExecuteScript(claim, locking, recipientChecker)
+	assert.NotNil(t, err, "Wrong secret is rejected")
+
+	_, err = NewHTLCClaimScript(signature, recipientKey, make([]byte, maxScriptElementSize+1))
+	assert.NotNil(t, err, "A secret longer than a script element can't be pushed")
+
+	err = ExecuteScript(NewHTLCRefundScript(signature, recipientKey), locking, lockTimeChecker{recipientChecker.fakeSigChecker, 100})
+	assert.NotNil(t, err, "Recipient can't take the refund path")
+
+	senderChecker := lockTimeChecker{fakeSigChecker{signature, senderKey}, 99}
+	err = ExecuteScript(NewHTLCRefundScript(signature, senderKey), locking, senderChecker)
+	assert.NotNil(t, err, "Refund is rejected before the timeout")
+
+	senderChecker.lockTime = 100
+	err = ExecuteScript(NewHTLCRefundScript(signature, senderKey), locking, senderChecker)
+	assert.Nil(t, err, "Sender refunds after the timeout")
+}
+
+func TestDataCarrier(t *testing.T) {
+	data := []byte("document hash")
+	out := NewDataCarrierOutput(data)
+
+	assert.Equal(t, data, extractDataCarrier(out.ScriptPubKey))
+	assert.True(t, out.IsUnspendable())
+	assert.True(t, out.IsValidDataCarrier())
+	assert.NotNil(t, ExecuteScript([]byte{Op1}, out.ScriptPubKey, nil), "Data-carrier outputs can't be spent")
+
+	out.Value = 1
+	assert.False(t, out.IsValidDataCarrier(), "Data-carrier outputs carry no value")
+
+	out = &TXOutput{0, NewDataCarrierScript(make([]byte, maxDataCarrierSize+1))}
+	assert.False(t, out.IsValidDataCarrier(), "Oversized data is rejected")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+)
+
+// koblitzCurve is a short Weierstrass curve y² = x³ + b. crypto/elliptic only implements
+// curves with a = -3, so secp256k1, which has a = 0, needs its own arithmetic.
+// Points are affine and the point at infinity is (0, 0), as in crypto/elliptic
+type koblitzCurve struct {
+	params *elliptic.CurveParams
+}
+
+var theSecp256k1 = newSecp256k1()
+
+func newSecp256k1() *koblitzCurve {
+	hexInt := func(s string) *big.Int {
+		v, _ := new(big.Int).SetString(s, 16)
+		return v
+	}
+
+	return &koblitzCurve{&elliptic.CurveParams{
+		P:       hexInt("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F"),
+		N:       hexInt("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141"),
+		B:       big.NewInt(7),
+		Gx:      hexInt("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798"),
+		Gy:      hexInt("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8"),
+		BitSize: 256,
+		Name:    "secp256k1",
+	}}
+}
+
+// secp256k1 returns the curve used by Bitcoin
+func secp256k1() elliptic.Curve {
+	return theSecp256k1
+}
+
+// Params returns the parameters of the curve. Its A coefficient is 0, unlike the
+// curves implemented by crypto/elliptic, so the generic CurveParams methods can't be used
+func (curve *koblitzCurve) Params() *elliptic.CurveParams {
+	return curve.params
+}
+
+// IsOnCurve reports whether the point (x, y) lies on the curve
+func (curve *koblitzCurve) IsOnCurve(x, y *big.Int) bool {
+	p := curve.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+
+	return y2.Cmp(curve.polynomial(x)) == 0
+}
+
+// polynomial returns x³ + b mod p
+func (curve *koblitzCurve) polynomial(x *big.Int) *big.Int {
+	x3 := new(big.Int).Mul(x, x)
+	x3.Mul(x3, x)
+	x3.Add(x3, curve.params.B)
+
+	return x3.Mod(x3, curve.params.P)
+}
+
+// Add returns the sum of (x1, y1) and (x2, y2)
+func (curve *koblitzCurve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	p := curve.params.P
+
+	if isInfinity(x1, y1) {
+		return new(big.Int).Set(x2), new(big.Int).Set(y2)
+	}
+	if isInfinity(x2, y2) {
+		return new(big.Int).Set(x1), new(big.Int).Set(y1)
+	}
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) == 0 {
+			return curve.Double(x1, y1)
+		}
+		return new(big.Int), new(big.Int)
+	}
+
+	// λ = (y2 - y1) / (x2 - x1)
+	lambda := new(big.Int).Sub(x2, x1)
+	lambda.ModInverse(lambda.Mod(lambda, p), p)
+	lambda.Mul(lambda, new(big.Int).Sub(y2, y1))
+	lambda.Mod(lambda, p)
+
+	return curve.finish(lambda, x1, y1, x2)
+}
+
+// Double returns 2 * (x1, y1)
+func (curve *koblitzCurve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	p := curve.params.P
+
+	if isInfinity(x1, y1) || y1.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+
+	// λ = 3x² / 2y
+	lambda := new(big.Int).Lsh(y1, 1)
+	lambda.ModInverse(lambda.Mod(lambda, p), p)
+	lambda.Mul(lambda, new(big.Int).Mul(big.NewInt(3), new(big.Int).Mul(x1, x1)))
+	lambda.Mod(lambda, p)
+
+	return curve.finish(lambda, x1, y1, x1)
+}
+
+// finish computes the point with x3 = λ² - x1 - x2 and y3 = λ(x1 - x3) - y1
+func (curve *koblitzCurve) finish(lambda, x1, y1, x2 *big.Int) (*big.Int, *big.Int) {
+	p := curve.params.P
+
+	x3 := new(big.Int).Mul(lambda, lambda)
+	x3.Sub(x3, x1)
+	x3.Sub(x3, x2)
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, lambda)
+	y3.Sub(y3, y1)
+	y3.Mod(y3, p)
+
+	return x3, y3
+}
+
+// ScalarMult returns k * (x, y), where k is a big-endian integer
+func (curve *koblitzCurve) ScalarMult(x, y *big.Int, k []byte) (*big.Int, *big.Int) {
+	rx, ry := new(big.Int), new(big.Int)
+
+	for _, b := range k {
+		for bit := 7; bit >= 0; bit-- {
+			rx, ry = curve.Double(rx, ry)
+			if b>>uint(bit)&1 == 1 {
+				rx, ry = curve.Add(rx, ry, x, y)
+			}
+		}
+	}
+
+	return rx, ry
+}
+
+// ScalarBaseMult returns k * G, where G is the base point of the curve
+func (curve *koblitzCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+	return curve.ScalarMult(curve.params.Gx, curve.params.Gy, k)
+}
+
+// decompressPoint returns the point with the given x coordinate and y parity
+func (curve *koblitzCurve) decompressPoint(x *big.Int, odd bool) (*big.Int, *big.Int) {
+	p := curve.params.P
+	if x.Cmp(p) >= 0 {
+		return nil, nil
+	}
+
+	y := new(big.Int).ModSqrt(curve.polynomial(x), p)
+	if y == nil {
+		return nil, nil
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(p, y)
+	}
+
+	return new(big.Int).Set(x), y
+}
+
+func isInfinity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Sign() == 0
+}
+
+// verifyECDSA verifies an ECDSA signature on any curve implementing elliptic.Curve
+func verifyECDSA(curve elliptic.Curve, x, y *big.Int, digest []byte, r, s *big.Int) bool {
+	n := curve.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+
+	e := hashToInt(digest, n)
+	w := new(big.Int).ModInverse(s, n)
+
+	u1 := new(big.Int).Mul(e, w)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, n)
+
+	x1, y1 := curve.ScalarBaseMult(u1.Bytes())
+	x2, y2 := curve.ScalarMult(x, y, u2.Bytes())
+	rx, ry := curve.Add(x1, y1, x2, y2)
+	if isInfinity(rx, ry) {
+		return false
+	}
+
+	return rx.Mod(rx, n).Cmp(r) == 0
+}
+
+// taggedHash is the BIP340 tagged hash SHA256(SHA256(tag) || SHA256(tag) || data)
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// schnorrSign creates a BIP340 Schnorr signature of a 32-byte message with the private key d
+// and auxiliary random data aux. Signing with the same aux twice gives the same signature
+func schnorrSign(d *big.Int, msg, aux []byte) []byte {
+	curve := theSecp256k1
+	n := curve.params.N
+
+	px, py := curve.ScalarBaseMult(d.Bytes())
+	if py.Bit(0) == 1 {
+		d = new(big.Int).Sub(n, d)
+	}
+	pBytes := px.FillBytes(make([]byte, 32))
+
+	t := d.FillBytes(make([]byte, 32))
+	auxHash := taggedHash("BIP0340/aux", aux)
+	for i := range t {
+		t[i] ^= auxHash[i]
+	}
+
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, pBytes, msg))
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil
+	}
+
+	rx, ry := curve.ScalarBaseMult(k.Bytes())
+	if ry.Bit(0) == 1 {
+		k.Sub(n, k)
+	}
+	rBytes := rx.FillBytes(make([]byte, 32))
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", rBytes, pBytes, msg))
+	e.Mod(e, n)
+
+	s := e.Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, n)
+
+	return append(rBytes, s.FillBytes(make([]byte, 32))...)
+}
+
+// schnorrVerify verifies a BIP340 Schnorr signature of msg made with the x-only public key pubKey
+func schnorrVerify(pubKey, msg, signature []byte) bool {
+	curve := theSecp256k1
+	p, n := curve.params.P, curve.params.N
+
+	if len(pubKey) != 32 || len(signature) != 64 {
+		return false
+	}
+
+	px, py := curve.decompressPoint(new(big.Int).SetBytes(pubKey), false)
+	if px == nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if r.Cmp(p) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", signature[:32], pubKey, msg))
+	e.Mod(e, n)
+
+	// R = s⋅G - e⋅P
+	sx, sy := curve.ScalarBaseMult(s.Bytes())
+	ex, ey := curve.ScalarMult(px, py, e.Bytes())
+	if !isInfinity(ex, ey) {
+		ey.Sub(p, ey)
+	}
+	rx, ry := curve.Add(sx, sy, ex, ey)
+
+	return !isInfinity(rx, ry) && ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecp256k1(t *testing.T) {
+	curve := secp256k1()
+	params := curve.Params()
+
+	x, y := curve.ScalarBaseMult([]byte{1})
+	assert.Equal(t, params.Gx, x)
+	assert.Equal(t, params.Gy, y)
+	assert.True(t, curve.IsOnCurve(x, y))
+
+	x2, y2 := curve.ScalarBaseMult([]byte{2})
+	dx, dy := curve.Double(x, y)
+	assert.Equal(t, x2, dx)
+	assert.Equal(t, y2, dy)
+
+	x3, y3 := curve.Add(x2, y2, x, y)
+	assert.True(t, curve.IsOnCurve(x3, y3))
+
+	nx, ny := curve.ScalarBaseMult(params.N.Bytes())
+	assert.True(t, isInfinity(nx, ny), "n * G is the point at infinity")
+}
+
+// Test vector used by several secp256k1 libraries: private key 1 signing "Satoshi Nakamoto"
+func TestSecp256k1RFC6979(t *testing.T) {
+	privKey := PrivateKey{SchemeSecp256k1, big.NewInt(1).FillBytes(make([]byte, 32))}
+	hash := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	n := secp256k1().Params().N
+
+	k := nonceRFC6979(secp256k1(), big.NewInt(1), hash[:])()
+	assert.Equal(t, hexInt("8F8A276C19F4149656B280621E358CCE24F5F52542772691EE69063B74F15D15"), k)
+
+	signature := privKey.Sign(hash[:])
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	assert.Equal(t, hexInt("934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8"), r)
+	assert.Equal(t, hexInt("2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"), s)
+	assert.True(t, verifySignature(privKey.PublicKey(), signature, hash[:]))
+}
+
+// Test vectors 0 and 1 from BIP340
+func TestSchnorr(t *testing.T) {
+	vectors := []struct {
+		secretKey string
+		publicKey string
+		aux       string
+		message   string
+		signature string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		},
+		{
+			"B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		},
+	}
+
+	for _, v := range vectors {
+		secretKey, _ := hex.DecodeString(v.secretKey)
+		publicKey, _ := hex.DecodeString(v.publicKey)
+		aux, _ := hex.DecodeString(v.aux)
+		message, _ := hex.DecodeString(v.message)
+		expected, _ := hex.DecodeString(v.signature)
+
+		privKey := PrivateKey{SchemeSchnorr, secretKey}
+		assert.Equal(t, append([]byte{byte(SchemeSchnorr)}, publicKey...), privKey.PublicKey())
+
+		signature := schnorrSign(new(big.Int).SetBytes(secretKey), message, aux)
+		assert.Equal(t, expected, signature)
+		assert.True(t, schnorrVerify(publicKey, message, signature))
+
+		signature[63] ^= 0x01
+		assert.False(t, schnorrVerify(publicKey, message, signature))
+	}
+}
@@ -3,23 +3,27 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
+	"time"
 )
 
 const protocol = "tcp"
 const nodeVersion = 1
 const commandLength = 12
 
+// maxMessageSize bounds the messages read from peers. The largest message is a block,
+// and its gob encoding stays well within twice its canonical size
+const maxMessageSize = commandLength + 2*maxBlockSize
+
 var nodeAddress string
 var miningAddress string
 var knownNodes = []string{"localhost:3000"}
 var blocksInTransit = [][]byte{}
-var mempool = make(map[string]Transaction)
 
 type addr struct {
 	AddrList []string
@@ -169,7 +173,7 @@ func sendVersion(addr string, bc *Blockchain) {
 	sendData(addr, request)
 }
 
-func handleAddr(request []byte) {
+func handleAddr(request []byte) error {
 	var buff bytes.Buffer
 	var payload addr
 
@@ -177,15 +181,17 @@ func handleAddr(request []byte) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	knownNodes = append(knownNodes, payload.AddrList...)
 	fmt.Printf("There are %d known nodes now!\n", len(knownNodes))
 	requestBlocks()
+
+	return nil
 }
 
-func handleBlock(request []byte, bc *Blockchain) {
+func handleBlock(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload block
 
@@ -193,14 +199,26 @@ func handleBlock(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	blockData := payload.Block
-	block := DeserializeBlock(blockData)
+	block, err := DeserializeBlock(blockData)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("Recevied a new block!")
-	bc.AddBlock(block)
+
+	if err := bc.AcceptBlock(block); err != nil {
+		// The blocks after a rejected one don't extend the chain either
+		if len(blocksInTransit) > 0 {
+			fmt.Printf("Dropping %d blocks in transit from %s\n", len(blocksInTransit), payload.AddrFrom)
+			blocksInTransit = [][]byte{}
+		}
+
+		return err
+	}
 
 	fmt.Printf("Added block %x\n", block.Hash)
 
@@ -210,12 +228,13 @@ func handleBlock(request []byte, bc *Blockchain) {
 
 		blocksInTransit = blocksInTransit[1:]
 	} else {
-		UTXOSet := UTXOSet{bc}
-		UTXOSet.Reindex()
+		Mempool{bc}.Revalidate()
 	}
+
+	return nil
 }
 
-func handleInv(request []byte, bc *Blockchain) {
+func handleInv(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload inv
 
@@ -223,36 +242,40 @@ func handleInv(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
+	if len(payload.Items) == 0 {
+		return errors.New("Inventory is empty")
+	}
+
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		// Blocks are listed newest first, but each block has to be added after its parent
+		blocksInTransit = [][]byte{}
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			blocksInTransit = append(blocksInTransit, payload.Items[i])
+		}
 
-		blockHash := payload.Items[0]
+		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
-		newInTransit := [][]byte{}
-		for _, b := range blocksInTransit {
-			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
-			}
-		}
-		blocksInTransit = newInTransit
+		blocksInTransit = blocksInTransit[1:]
 	}
 
 	if payload.Type == "tx" {
 		txID := payload.Items[0]
 
-		if mempool[hex.EncodeToString(txID)].ID == nil {
+		if _, err := (Mempool{bc}).FindTransaction(txID); err != nil {
 			sendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
+
+	return nil
 }
 
-func handleGetBlocks(request []byte, bc *Blockchain) {
+func handleGetBlocks(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload getblocks
 
@@ -260,14 +283,16 @@ func handleGetBlocks(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	blocks := bc.GetBlockHashes()
 	sendInv(payload.AddrFrom, "block", blocks)
+
+	return nil
 }
 
-func handleGetData(request []byte, bc *Blockchain) {
+func handleGetData(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload getdata
 
@@ -275,28 +300,31 @@ func handleGetData(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	if payload.Type == "block" {
 		block, err := bc.GetBlock([]byte(payload.ID))
 		if err != nil {
-			return
+			return err
 		}
 
 		sendBlock(payload.AddrFrom, &block)
 	}
 
 	if payload.Type == "tx" {
-		txID := hex.EncodeToString(payload.ID)
-		tx := mempool[txID]
+		tx, err := Mempool{bc}.FindTransaction(payload.ID)
+		if err != nil {
+			return err
+		}
 
 		sendTx(payload.AddrFrom, &tx)
-		// delete(mempool, txID)
 	}
+
+	return nil
 }
 
-func handleTx(request []byte, bc *Blockchain) {
+func handleTx(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload tx
 
@@ -304,12 +332,51 @@ func handleTx(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	txData := payload.Transaction
-	tx := DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+	tx, err := DeserializeTransaction(txData)
+	if err != nil {
+		return err
+	}
+
+	nextHeight := bc.GetBestHeight() + 1
+	now := time.Now().Unix()
+
+	if !tx.IsFinal(nextHeight, now) {
+		return validationError("non-final", "transaction %x is locked until %d", tx.ID, tx.LockTime)
+	}
+
+	if err := CheckTransaction(&tx); err != nil {
+		return err
+	}
+
+	if err := CheckStandard(&tx); err != nil {
+		return fmt.Errorf("transaction %x is not standard: %s", tx.ID, err)
+	}
+
+	UTXOSet := UTXOSet{bc}
+	mempool := Mempool{bc}
+	if conflict := mempool.Conflict(&tx); conflict != nil {
+		return fmt.Errorf("transaction %x double-spends transaction %x in the mempool", tx.ID, conflict.ID)
+	}
+	view, _, err := mempool.View()
+	if err != nil {
+		return err
+	}
+	if _, err := CheckTransactionInputs(&tx, view); err != nil {
+		return err
+	}
+	if !UTXOSet.CheckSequenceLocks(&tx, nextHeight, now) {
+		return validationError("sequence-locks", "transaction %x spends outputs that are not old enough", tx.ID)
+	}
+
+	if err := bc.VerifyTransaction(&tx); err != nil {
+		return err
+	}
+
+	mempool.Add(&tx)
 
 	if nodeAddress == knownNodes[0] {
 		for _, node := range knownNodes {
@@ -318,35 +385,35 @@ func handleTx(request []byte, bc *Blockchain) {
 			}
 		}
 	} else {
-		if len(mempool) >= 2 && len(miningAddress) > 0 {
+		if mempool.Count() >= 2 && len(miningAddress) > 0 {
 		MineTransactions:
 			var txs []*Transaction
 
-			for id := range mempool {
-				tx := mempool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
+			mempool.Revalidate()
+			for _, tx := range mempool.Transactions() {
+				if bc.VerifyTransaction(tx) == nil {
+					txs = append(txs, tx)
 				}
 			}
 
 			if len(txs) == 0 {
 				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
+				return nil
 			}
 
 			cbTx := NewCoinbaseTX(miningAddress, "")
-			txs = append(txs, cbTx)
+			txs = NewBlockTemplate(cbTx, txs)
 
-			newBlock := bc.MineBlock(txs)
-			UTXOSet := UTXOSet{bc}
+			newBlock, err := bc.MineBlock(txs)
+			if err != nil {
+				fmt.Printf("Mining failed: %s\n", err)
+				return nil
+			}
 			UTXOSet.Reindex()
 
 			fmt.Println("New block is mined!")
 
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(mempool, txID)
-			}
+			mempool.Revalidate()
 
 			for _, node := range knownNodes {
 				if node != nodeAddress {
@@ -354,14 +421,16 @@ func handleTx(request []byte, bc *Blockchain) {
 				}
 			}
 
-			if len(mempool) > 0 {
+			if mempool.Count() > 0 {
 				goto MineTransactions
 			}
 		}
 	}
+
+	return nil
 }
 
-func handleVersion(request []byte, bc *Blockchain) {
+func handleVersion(request []byte, bc *Blockchain) error {
 	var buff bytes.Buffer
 	var payload verzion
 
@@ -369,7 +438,7 @@ func handleVersion(request []byte, bc *Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	myBestHeight := bc.GetBestHeight()
@@ -385,36 +454,51 @@ func handleVersion(request []byte, bc *Blockchain) {
 	if !nodeIsKnown(payload.AddrFrom) {
 		knownNodes = append(knownNodes, payload.AddrFrom)
 	}
+
+	return nil
 }
 
 func handleConnection(conn net.Conn, bc *Blockchain) {
-	request, err := ioutil.ReadAll(conn)
+	defer conn.Close()
+
+	request, err := ioutil.ReadAll(io.LimitReader(conn, maxMessageSize+1))
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("Reading from %s failed: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	if len(request) > maxMessageSize {
+		fmt.Printf("Message from %s exceeds %d bytes, dropping\n", conn.RemoteAddr(), maxMessageSize)
+		return
+	}
+	if len(request) < commandLength {
+		fmt.Printf("Message from %s is too short, dropping\n", conn.RemoteAddr())
+		return
 	}
 	command := bytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
 	switch command {
 	case "addr":
-		handleAddr(request)
+		err = handleAddr(request)
 	case "block":
-		handleBlock(request, bc)
+		err = handleBlock(request, bc)
 	case "inv":
-		handleInv(request, bc)
+		err = handleInv(request, bc)
 	case "getblocks":
-		handleGetBlocks(request, bc)
+		err = handleGetBlocks(request, bc)
 	case "getdata":
-		handleGetData(request, bc)
+		err = handleGetData(request, bc)
 	case "tx":
-		handleTx(request, bc)
+		err = handleTx(request, bc)
 	case "version":
-		handleVersion(request, bc)
+		err = handleVersion(request, bc)
 	default:
 		fmt.Println("Unknown command!")
 	}
 
-	conn.Close()
+	if err != nil {
+		fmt.Printf("Rejected %s message from %s: %s\n", command, conn.RemoteAddr(), err)
+	}
 }
 
 // StartServer starts a node
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+)
+
+// maxSigCacheEntries bounds the number of signatures remembered by the signature cache
+const maxSigCacheEntries = 50000
+
+// signatureCache holds the signatures verified by this node. A transaction admitted to
+// the mempool has its signatures checked once, so they don't need checking again when
+// it's mined or relayed
+var signatureCache = newSigCache(maxSigCacheEntries)
+
+// sigCache is a bounded set of (digest, public key, signature) triples known to be valid.
+// It's safe for concurrent use
+type sigCache struct {
+	mu         sync.RWMutex
+	entries    map[[sha256.Size]byte]struct{}
+	maxEntries int
+}
+
+func newSigCache(maxEntries int) *sigCache {
+	return &sigCache{entries: make(map[[sha256.Size]byte]struct{}), maxEntries: maxEntries}
+}
+
+// Exists checks whether the signature was added to the cache
+func (c *sigCache) Exists(digest, pubKey, signature []byte) bool {
+	key := sigCacheKey(digest, pubKey, signature)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.entries[key]
+
+	return ok
+}
+
+// Add remembers a valid signature. When the cache is full, a random entry is evicted,
+// so that an attacker can't predict which signatures have to be verified again
+func (c *sigCache) Add(digest, pubKey, signature []byte) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	key := sigCacheKey(digest, pubKey, signature)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		// Map iteration order is randomized
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+
+	c.entries[key] = struct{}{}
+}
+
+// Len returns the number of cached signatures
+func (c *sigCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+// sigCacheKey hashes a triple with length prefixes, so that different triples can't collide
+// by moving bytes from one field to the next
+func sigCacheKey(digest, pubKey, signature []byte) [sha256.Size]byte {
+	var buff bytes.Buffer
+
+	writeVarBytes(&buff, digest)
+	writeVarBytes(&buff, pubKey)
+	writeVarBytes(&buff, signature)
+
+	return sha256.Sum256(buff.Bytes())
+}
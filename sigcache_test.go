@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigCache(t *testing.T) {
+	cache := newSigCache(10)
+
+	cache.Add([]byte("digest"), []byte("key"), []byte("signature"))
+	assert.True(t, cache.Exists([]byte("digest"), []byte("key"), []byte("signature")))
+	assert.False(t, cache.Exists([]byte("digest"), []byte("keys"), []byte("ignature")))
+
+	for i := 0; i < 100; i++ {
+		cache.Add([]byte(fmt.Sprintf("digest %d", i)), []byte("key"), []byte("signature"))
+	}
+	assert.Equal(t, 10, cache.Len())
+	assert.True(t, cache.Exists([]byte("digest 99"), []byte("key"), []byte("signature")))
+}
+
+func TestVerifyInputs(t *testing.T) {
+	privKey := NewPrivateKey(SchemeSchnorr)
+	pubKeyHash := HashPubKey(privKey.PublicKey())
+
+	prevTx := Transaction{[]byte("previous"), nil, nil, 0}
+	tx := &Transaction{}
+	for i := 0; i < 32; i++ {
+		prevTx.Vout = append(prevTx.Vout, TXOutput{1, NewP2PKHScript(pubKeyHash)})
+		tx.Vin = append(tx.Vin, TXInput{prevTx.ID, i, nil, sequenceFinal})
+	}
+	tx.Vout = []TXOutput{{32, NewP2PKHScript(pubKeyHash)}}
+	prevTXs := map[string]Transaction{fmt.Sprintf("%x", prevTx.ID): prevTx}
+
+	assert.Nil(t, tx.Sign(privKey, SigHashAll, prevTXs, testChainID))
+	assert.Nil(t, tx.Verify(prevTXs, testChainID))
+
+	cached := signatureCache.Len()
+	assert.Nil(t, tx.Verify(prevTXs, testChainID), "Cached signatures verify")
+	assert.Equal(t, cached, signatureCache.Len())
+
+	tx.Vin[17].ScriptSig = tx.Vin[16].ScriptSig
+	assert.NotNil(t, tx.Verify(prevTXs, testChainID), "A single invalid input fails the transaction")
+}
+
+func TestSigCacheSkipsVerification(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	pubKey := privKey.PublicKey()
+	script := NewP2PKHScript(HashPubKey(pubKey))
+
+	prevTx := Transaction{[]byte("previous"), nil, []TXOutput{{1, script}}, 0}
+	tx := &Transaction{nil, []TXInput{{prevTx.ID, 0, nil, sequenceFinal}}, []TXOutput{{1, script}}, 0}
+	tx.ID = tx.Hash()
+	prevTXs := map[string]Transaction{fmt.Sprintf("%x", prevTx.ID): prevTx}
+
+	forged := []byte("forged")
+	tx.Vin[0].ScriptSig = NewP2PKHUnlockingScript(append(forged, byte(SigHashAll)), pubKey)
+	assert.NotNil(t, tx.Verify(prevTXs, testChainID))
+
+	signatureCache.Add(tx.signatureDigest(0, script, SigHashAll, testChainID), pubKey, forged)
+	assert.Nil(t, tx.Verify(prevTXs, testChainID), "A cached signature is not verified again")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"log"
+	"math/big"
+)
+
+// ECDSA public keys are encoded as SEC1 points, compressed by default, behind their
+// scheme byte. ECDSA signatures are fixed-width r || s, each padded to the curve size,
+// or strict DER. Schnorr keys and signatures follow BIP340
+
+// ecdsaSignature is the ASN.1 structure of a DER-encoded signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// serializePubKey returns the SEC1 compressed encoding of an ECDSA public key
+func serializePubKey(pubKey ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(pubKey.Curve, pubKey.X, pubKey.Y)
+}
+
+// parsePubKey decodes a public key of any scheme: a SEC1 compressed or uncompressed
+// point for ECDSA keys, or an x coordinate for Schnorr keys
+func parsePubKey(data []byte) (*ecdsa.PublicKey, error) {
+	scheme := pubKeyScheme(data)
+	curve := scheme.curve()
+	if scheme != SchemeP256 {
+		data = data[1:]
+	}
+
+	var x, y *big.Int
+	switch {
+	case scheme == SchemeSchnorr:
+		if len(data) == 32 {
+			x, y = theSecp256k1.decompressPoint(new(big.Int).SetBytes(data), false)
+		}
+	case len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		if scheme == SchemeP256 {
+			x, y = elliptic.UnmarshalCompressed(curve, data)
+		} else {
+			x, y = theSecp256k1.decompressPoint(new(big.Int).SetBytes(data[1:]), data[0] == 0x03)
+		}
+	case len(data) == 65 && data[0] == 0x04:
+		x = new(big.Int).SetBytes(data[1:33])
+		y = new(big.Int).SetBytes(data[33:])
+		if !curve.IsOnCurve(x, y) {
+			x = nil
+		}
+	}
+
+	if x == nil {
+		return nil, errors.New("Public key is not a valid point")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// signDigest signs data with an ECDSA private key and returns a fixed-width signature.
+// The nonce is derived from the key and data as specified by RFC 6979, so signing
+// the same data twice gives the same signature
+func signDigest(privKey ecdsa.PrivateKey, data []byte) []byte {
+	r, s := signRFC6979(privKey, data)
+
+	size := (privKey.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signature
+}
+
+// encodeSignatureDER converts a fixed-width signature to DER
+func encodeSignatureDER(signature []byte) []byte {
+	size := len(signature) / 2
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+
+	der, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return der
+}
+
+// parseSignatureDER decodes a strict DER signature. Encodings that are not the shortest
+// possible are rejected, so that a signature can't be altered without invalidating it
+func parseSignatureDER(signature []byte) (*big.Int, *big.Int, bool) {
+	var sig ecdsaSignature
+
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil || len(rest) != 0 || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return nil, nil, false
+	}
+
+	canonical, err := asn1.Marshal(sig)
+	if err != nil || !bytes.Equal(canonical, signature) {
+		return nil, nil, false
+	}
+
+	return sig.R, sig.S, true
+}
+
+// verifySignature verifies a signature over digest made with a public key of any scheme
+func verifySignature(pubKey, signature, digest []byte) bool {
+	key, err := parsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	if pubKeyScheme(pubKey) == SchemeSchnorr {
+		return schnorrVerify(pubKey[1:], digest, signature)
+	}
+
+	if r, s, ok := parseSignatureDER(signature); ok && verifyECDSAKey(key, digest, r, s) {
+		return true
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+
+	return verifyECDSAKey(key, digest, r, s)
+}
+
+// verifyECDSAKey verifies an ECDSA signature, using crypto/ecdsa for the curves it implements
+func verifyECDSAKey(key *ecdsa.PublicKey, digest []byte, r, s *big.Int) bool {
+	if key.Curve == elliptic.P256() {
+		return ecdsa.Verify(key, digest, r, s)
+	}
+
+	return verifyECDSA(key.Curve, key.X, key.Y, digest, r, s)
+}
@@ -2,26 +2,30 @@ package main
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"math/big"
 	"strings"
 
 	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
-const subsidy = 10
+// subsidy is the amount a coinbase pays on top of the fees of its block
+const subsidy = 10 * coin
+
+// lockTimeThreshold separates lock times given as block heights from UNIX timestamps
+const lockTimeThreshold = 500000000
 
 // Transaction represents a Bitcoin transaction
 type Transaction struct {
-	ID   []byte
-	Vin  []TXInput
-	Vout []TXOutput
+	ID       []byte
+	Vin      []TXInput
+	Vout     []TXOutput
+	LockTime int64
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -29,6 +33,20 @@ func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
+// IsFinal checks whether the transaction's lock time allows it to be included
+// in a block at the given height and time
+func (tx Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if tx.LockTime < lockTimeThreshold {
+		return tx.LockTime < int64(height)
+	}
+
+	return tx.LockTime < blockTime
+}
+
 // Serialize returns a serialized Transaction
 func (tx Transaction) Serialize() []byte {
 	var encoded bytes.Buffer
@@ -42,48 +60,234 @@ func (tx Transaction) Serialize() []byte {
 	return encoded.Bytes()
 }
 
-// Hash returns the hash of the Transaction
+// SerializeCanonical encodes the transaction in a compact binary format, laid out like the
+// sighash preimage but with every input's own script and no hash type. Its length is the
+// size of the transaction that counts towards the block size limit
+func (tx Transaction) SerializeCanonical() []byte {
+	var buff bytes.Buffer
+
+	writeVarInt(&buff, uint64(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		writeVarBytes(&buff, vin.Txid)
+		writeUint32(&buff, uint32(vin.Vout))
+		writeVarBytes(&buff, vin.ScriptSig)
+		writeUint32(&buff, vin.Sequence)
+	}
+
+	writeVarInt(&buff, uint64(len(tx.Vout)))
+	for _, vout := range tx.Vout {
+		writeUint64(&buff, uint64(vout.Value))
+		writeVarBytes(&buff, vout.ScriptPubKey)
+	}
+
+	writeUint64(&buff, uint64(tx.LockTime))
+
+	return buff.Bytes()
+}
+
+// DeserializeCanonical decodes a transaction encoded by SerializeCanonical and computes its ID
+func DeserializeCanonical(data []byte) (Transaction, error) {
+	var tx Transaction
+	r := bytes.NewReader(data)
+
+	inputs, err := readVarInt(r)
+	if err != nil {
+		return tx, err
+	}
+	for i := uint64(0); i < inputs; i++ {
+		var vin TXInput
+
+		if vin.Txid, err = readVarBytes(r); err != nil {
+			return tx, err
+		}
+		vout, err := readUint32(r)
+		if err != nil {
+			return tx, err
+		}
+		vin.Vout = int(int32(vout))
+		if vin.ScriptSig, err = readVarBytes(r); err != nil {
+			return tx, err
+		}
+		if vin.Sequence, err = readUint32(r); err != nil {
+			return tx, err
+		}
+
+		tx.Vin = append(tx.Vin, vin)
+	}
+
+	outputs, err := readVarInt(r)
+	if err != nil {
+		return tx, err
+	}
+	for i := uint64(0); i < outputs; i++ {
+		var vout TXOutput
+
+		value, err := readUint64(r)
+		if err != nil {
+			return tx, err
+		}
+		vout.Value = Amount(int64(value))
+		if vout.ScriptPubKey, err = readVarBytes(r); err != nil {
+			return tx, err
+		}
+
+		tx.Vout = append(tx.Vout, vout)
+	}
+
+	lockTime, err := readUint64(r)
+	if err != nil {
+		return tx, err
+	}
+	tx.LockTime = int64(lockTime)
+
+	if r.Len() != 0 {
+		return tx, fmt.Errorf("%d trailing bytes after transaction", r.Len())
+	}
+
+	tx.ID = tx.Hash()
+
+	return tx, nil
+}
+
+// Size returns the canonical size of the transaction in bytes
+func (tx Transaction) Size() int {
+	return len(tx.SerializeCanonical())
+}
+
+// Hash returns the ID of the Transaction: the hash of the transaction without its unlocking
+// scripts, so that re-encoding a signature doesn't change the ID. The script of a coinbase input
+// is kept, since it unlocks nothing and makes coinbase IDs unique. The canonical encoding is
+// hashed, as gob encodings depend on the types a process encoded before
 func (tx *Transaction) Hash() []byte {
 	var hash [32]byte
 
-	txCopy := *tx
-	txCopy.ID = []byte{}
+	txCopy := tx.TrimmedCopy()
+	if tx.IsCoinbase() {
+		txCopy.Vin[0].ScriptSig = tx.Vin[0].ScriptSig
+	}
+
+	hash = sha256.Sum256(txCopy.SerializeCanonical())
+
+	return hash[:]
+}
+
+// WitnessHash returns the hash of the whole Transaction, unlocking scripts included
+func (tx *Transaction) WitnessHash() []byte {
+	var hash [32]byte
 
-	hash = sha256.Sum256(txCopy.Serialize())
+	hash = sha256.Sum256(tx.SerializeCanonical())
 
 	return hash[:]
 }
 
-// Sign signs each input of a Transaction
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// Sign signs each input of a Transaction that spends an output locked with privKey's
+// public key hash, committing to the parts of the transaction selected by hashType and
+// to the chain chainID
+func (tx *Transaction) Sign(privKey PrivateKey, hashType SigHashType, prevTXs map[string]Transaction, chainID []byte) error {
 	if tx.IsCoinbase() {
-		return
+		return nil
+	}
+	if len(chainID) == 0 {
+		return errNoChainID
 	}
 
-	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+	prevOuts, err := tx.prevOutputs(prevTXs)
+	if err != nil {
+		return err
+	}
+
+	pubKey := privKey.PublicKey()
+
+	for inID, prevOut := range prevOuts {
+		if !prevOut.IsLockedWithKey(HashPubKey(pubKey)) {
+			continue
 		}
+		if !tx.canSign(inID, hashType) {
+			return fmt.Errorf("Input %d has no output to sign with SIGHASH_SINGLE", inID)
+		}
+
+		signature := tx.signInput(privKey, inID, prevOut.ScriptPubKey, hashType, chainID)
+
+		tx.Vin[inID].ScriptSig = NewP2PKHUnlockingScript(signature, pubKey)
 	}
 
-	txCopy := tx.TrimmedCopy()
+	return nil
+}
 
-	for inID, vin := range txCopy.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+// SignMultisig signs each input of a Transaction spending multisig outputs, either bare
+// or wrapped in P2SH with the given redeem script.
+// Signatures are made for the chain chainID with the keys that match the output's public
+// keys, in order
+func (tx *Transaction) SignMultisig(privKeys []PrivateKey, redeemScript []byte, prevTXs map[string]Transaction, chainID []byte) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if len(chainID) == 0 {
+		return errNoChainID
+	}
 
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
+	prevOuts, err := tx.prevOutputs(prevTXs)
+	if err != nil {
+		return err
+	}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
-		if err != nil {
-			log.Panic(err)
+	for inID, prevOut := range prevOuts {
+		prevScript := prevOut.ScriptPubKey
+
+		policy := prevScript
+		scriptHash := extractScriptHash(prevScript)
+		if scriptHash != nil {
+			if bytes.Compare(HashPubKey(redeemScript), scriptHash) != 0 {
+				return fmt.Errorf("Redeem script does not match output %d of transaction %x", tx.Vin[inID].Vout, tx.Vin[inID].Txid)
+			}
+			policy = redeemScript
+		}
+
+		m, pubKeys := extractMultisig(policy)
+		if m == 0 {
+			return fmt.Errorf("Output %d of transaction %x is not a multisig output", tx.Vin[inID].Vout, tx.Vin[inID].Txid)
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
 
-		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil
+		var signatures [][]byte
+		for _, pubKey := range pubKeys {
+			if len(signatures) == m {
+				break
+			}
+
+			for _, privKey := range privKeys {
+				if bytes.Equal(privKey.PublicKey(), pubKey) {
+					signatures = append(signatures, tx.signInput(privKey, inID, prevScript, SigHashAll, chainID))
+					break
+				}
+			}
+		}
+
+		if len(signatures) < m {
+			return fmt.Errorf("%d of %d required signatures provided", len(signatures), m)
+		}
+
+		tx.Vin[inID].ScriptSig = NewMultisigUnlockingScript(signatures)
+		if scriptHash != nil {
+			tx.Vin[inID].ScriptSig = append(tx.Vin[inID].ScriptSig, pushData(redeemScript)...)
+		}
 	}
+
+	return nil
+}
+
+// prevOutputs returns the output spent by each input, taken from prevTXs
+func (tx *Transaction) prevOutputs(prevTXs map[string]Transaction) ([]TXOutput, error) {
+	var prevOuts []TXOutput
+
+	for _, vin := range tx.Vin {
+		prevOut, err := prevOutput(prevTXs, vin)
+		if err != nil {
+			return nil, err
+		}
+		prevOuts = append(prevOuts, prevOut)
+	}
+
+	return prevOuts, nil
 }
 
 // String returns a human-readable representation of a transaction
@@ -91,20 +295,25 @@ func (tx Transaction) String() string {
 	var lines []string
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("     Lock time: %d", tx.LockTime))
+	}
 
 	for i, input := range tx.Vin {
 
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
 		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
-		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
-		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		lines = append(lines, fmt.Sprintf("       ScriptSig: %s", DisasmScript(input.ScriptSig)))
+		if input.Sequence != sequenceFinal {
+			lines = append(lines, fmt.Sprintf("       Sequence:  %x", input.Sequence))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
-		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
-		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("       Value:  %s", output.Value))
+		lines = append(lines, fmt.Sprintf("       Script: %s", DisasmScript(output.ScriptPubKey)))
 	}
 
 	return strings.Join(lines, "\n")
@@ -116,58 +325,167 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, vin.Sequence})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TXOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, TXOutput{vout.Value, vout.ScriptPubKey})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
 
 	return txCopy
 }
 
-// Verify verifies signatures of Transaction inputs
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+// Verify verifies that the unlocking script of each input satisfies the output it spends,
+// with signatures made for the chain chainID
+func (tx *Transaction) Verify(prevTXs map[string]Transaction, chainID []byte) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
-		}
+	checkers, err := tx.inputCheckers(prevTXs, chainID)
+	if err != nil {
+		return err
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
+	if !verifyInputs(checkers) {
+		return validationError("bad-signature", "transaction %x has an input script that fails", tx.ID)
+	}
+
+	return nil
+}
+
+// inputCheckers returns a checker for each input of the transaction, accepting signatures
+// made for the chain chainID
+func (tx *Transaction) inputCheckers(prevTXs map[string]Transaction, chainID []byte) ([]txChecker, error) {
+	var checkers []txChecker
+
+	prevOuts, err := tx.prevOutputs(prevTXs)
+	if err != nil {
+		return nil, err
+	}
+
+	for inID, prevOut := range prevOuts {
+		checkers = append(checkers, txChecker{tx, inID, prevOut.ScriptPubKey, chainID})
+	}
+
+	return checkers, nil
+}
 
-	for inID, vin := range tx.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+// scriptJob is an input script to run for a call of verifyInputs, which waits for its
+// jobs with wg and skips the rest once one of them has failed
+type scriptJob struct {
+	checker txChecker
+	failed  *atomic.Bool
+	wg      *sync.WaitGroup
+}
 
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
+// scriptJobs feeds a pool of workers, one per CPU, shared by all script verifications
+var scriptJobs = startScriptWorkers(runtime.NumCPU())
+
+// startScriptWorkers starts the workers running input scripts and returns the channel feeding them
+func startScriptWorkers(workers int) chan<- scriptJob {
+	jobs := make(chan scriptJob)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				if !job.failed.Load() {
+					scriptSig := job.checker.tx.Vin[job.checker.inID].ScriptSig
+					if ExecuteScript(scriptSig, job.checker.prevScript, job.checker) != nil {
+						job.failed.Store(true)
+					}
+				}
+				job.wg.Done()
+			}
+		}()
+	}
 
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
+	return jobs
+}
 
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
+// verifyInputs runs the scripts of the inputs on the script workers, and checks that all
+// of them succeed. The remaining inputs are skipped once an input fails
+func verifyInputs(checkers []txChecker) bool {
+	var failed atomic.Bool
+	var wg sync.WaitGroup
 
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
-			return false
+	for _, checker := range checkers {
+		if failed.Load() {
+			break
 		}
-		txCopy.Vin[inID].PubKey = nil
+		wg.Add(1)
+		scriptJobs <- scriptJob{checker, &failed, &wg}
 	}
+	wg.Wait()
+
+	return !failed.Load()
+}
+
+// txChecker checks signatures and lock times of a transaction input. Signatures must be
+// made for the chain chainID
+type txChecker struct {
+	tx         *Transaction
+	inID       int
+	prevScript []byte
+	chainID    []byte
+}
+
+// CheckLockTime checks that the transaction's lock time is of the same kind and at least
+// as late as the one required by the script. The lock itself is enforced by IsFinal
+func (c txChecker) CheckLockTime(lockTime int64) bool {
+	txLockTime := c.tx.LockTime
+
+	if (txLockTime < lockTimeThreshold) != (lockTime < lockTimeThreshold) {
+		return false
+	}
+
+	return txLockTime >= lockTime
+}
+
+// CheckSequence checks that the input's relative lock time is at least as strict as
+// the one required by the script. The lock itself is enforced against the age of the
+// spent output by UTXOSet.CheckSequenceLocks
+func (c txChecker) CheckSequence(sequence int64) bool {
+	txSequence := int64(c.tx.Vin[c.inID].Sequence)
+
+	if txSequence&sequenceDisableFlag != 0 {
+		return false
+	}
+	if txSequence&sequenceTypeFlag != sequence&sequenceTypeFlag {
+		return false
+	}
+
+	return txSequence&sequenceMask >= sequence&sequenceMask
+}
+
+// CheckSig verifies a signature made with pubKey over the input's digest.
+// The signature ends with its hash type byte. Valid signatures are kept in the
+// signature cache, so that they are only verified once
+func (c txChecker) CheckSig(signature, pubKey []byte) bool {
+	if len(signature) == 0 || len(pubKey) == 0 {
+		return false
+	}
+
+	hashType := SigHashType(signature[len(signature)-1])
+	if !hashType.IsValid() {
+		return false
+	}
+	signature = signature[:len(signature)-1]
+
+	dataToVerify := c.tx.signatureDigest(c.inID, c.prevScript, hashType, c.chainID)
+	if dataToVerify == nil {
+		return false
+	}
+
+	if signatureCache.Exists(dataToVerify, pubKey, signature) {
+		return true
+	}
+	if !verifySignature(pubKey, signature, dataToVerify) {
+		return false
+	}
+	signatureCache.Add(dataToVerify, pubKey, signature)
 
 	return true
 }
@@ -184,62 +502,141 @@ func NewCoinbaseTX(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
+	txin := TXInput{[]byte{}, -1, pushData([]byte(data)), sequenceFinal}
 	txout := NewTXOutput(subsidy, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}, 0}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-// NewUTXOTransaction creates a new transaction
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
-	var inputs []TXInput
+// NewUTXOTransaction creates a new transaction that can't be mined before lockTime.
+// When data is not empty, it is anchored in a data-carrier output.
+// Inputs are chosen by selector, and each pays feePerInput
+func NewUTXOTransaction(wallet *Wallet, to string, amount Amount, data []byte, lockTime int64, selector CoinSelector, feePerInput Amount, UTXOSet *UTXOSet) (*Transaction, error) {
+	from := fmt.Sprintf("%s", wallet.GetAddress())
+	payments := []TXOutput{*NewTXOutput(amount, to)}
+	if len(data) > 0 {
+		payments = append(payments, *NewDataCarrierOutput(data))
+	}
+
+	tx, err := newUnsignedTransaction(from, payments, lockTime, selector, feePerInput, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey, SigHashAll); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// NewSendManyTransaction creates a new transaction paying every payment from the wallet,
+// with a single change output. Inputs are chosen by selector, and each pays feePerInput
+func NewSendManyTransaction(wallet *Wallet, payments []Payment, selector CoinSelector, feePerInput Amount, UTXOSet *UTXOSet) (*Transaction, error) {
+	from := fmt.Sprintf("%s", wallet.GetAddress())
+
 	var outputs []TXOutput
+	for _, payment := range payments {
+		outputs = append(outputs, *NewTXOutput(payment.Amount, payment.Address))
+	}
 
-	pubKeyHash := HashPubKey(wallet.PublicKey)
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+	tx, err := newUnsignedTransaction(from, outputs, 0, selector, feePerInput, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
 
-	if acc < amount {
-		log.Panic("ERROR: Not enough funds")
+	if err := UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey, SigHashAll); err != nil {
+		return nil, err
 	}
 
-	// Build a list of inputs
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		if err != nil {
-			log.Panic(err)
+	return tx, nil
+}
+
+// NewMultisigTransaction creates a new transaction spending from a multisig address,
+// signed by the given wallets. redeemScript is required when from is a P2SH address
+func NewMultisigTransaction(from string, redeemScript []byte, signers []Wallet, to string, amount Amount, UTXOSet *UTXOSet) (*Transaction, error) {
+	var privKeys []PrivateKey
+	for _, signer := range signers {
+		privKeys = append(privKeys, signer.PrivateKey)
+	}
+
+	tx, err := newUnsignedTransaction(from, []TXOutput{*NewTXOutput(amount, to)}, 0, defaultCoinSelector, 0, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := UTXOSet.Blockchain.SignMultisigTransaction(tx, privKeys, redeemScript); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// newUnsignedTransaction creates a transaction with the given payment outputs funded
+// from outputs locked to the address from, confirmed or in the mempool, with change
+// returned to from.
+// The coins spent are chosen by selector, and the fee is feePerInput for every input.
+// Change worth no more than the fee of spending it, or less than the dust threshold that
+// peers relay, is left as fee
+func newUnsignedTransaction(from string, payments []TXOutput, lockTime int64, selector CoinSelector, feePerInput Amount, UTXOSet *UTXOSet) (*Transaction, error) {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	var amount Amount
+	for _, payment := range payments {
+		var err error
+		if amount, err = amount.Add(payment.Value); err != nil {
+			return nil, err
 		}
+	}
+	if !MoneyRange(feePerInput) {
+		return nil, errAmountRange
+	}
 
-		for _, out := range outs {
-			input := TXInput{txID, out, nil, wallet.PublicKey}
-			inputs = append(inputs, input)
+	available, err := Mempool{UTXOSet.Blockchain}.FindCoins(lockingScript([]byte(from)))
+	if err != nil {
+		return nil, err
+	}
+	coins, err := selector.Select(available, amount, feePerInput)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build a list of inputs
+	var acc Amount
+	for _, coin := range coins {
+		inputs = append(inputs, TXInput{coin.TxID, coin.Vout, nil, sequenceFinal})
+		if acc, err = acc.Add(coin.Value); err != nil {
+			return nil, err
 		}
 	}
 
 	// Build a list of outputs
-	from := fmt.Sprintf("%s", wallet.GetAddress())
-	outputs = append(outputs, *NewTXOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from)) // a change
+	outputs = append(outputs, payments...)
+	fee, err := feePerInput.MulInt(len(inputs))
+	if err != nil {
+		return nil, err
+	}
+	change := acc - amount - fee
+	changeOutput := NewTXOutput(change, from)
+	if change > feePerInput && change >= dustThreshold(*changeOutput) {
+		outputs = append(outputs, *changeOutput) // a change
 	}
 
-	tx := Transaction{nil, inputs, outputs}
+	tx := Transaction{nil, inputs, outputs, lockTime}
 	tx.ID = tx.Hash()
-	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
 
-	return &tx
+	return &tx, nil
 }
 
 // DeserializeTransaction deserializes a transaction
-func DeserializeTransaction(data []byte) Transaction {
+func DeserializeTransaction(data []byte) (Transaction, error) {
 	var transaction Transaction
 
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	err := decoder.Decode(&transaction)
-	if err != nil {
-		log.Panic(err)
-	}
 
-	return transaction
+	return transaction, err
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// NewHTLCTransaction creates a transaction locking amount in a hash time-locked output at index 0.
+// The address to can claim it with the preimage of secretHash, the wallet can refund it after timeout
+func NewHTLCTransaction(wallet *Wallet, to string, amount Amount, secretHash []byte, timeout int64, UTXOSet *UTXOSet) (*Transaction, error) {
+	if len(secretHash) != sha256.Size {
+		return nil, errors.New("Secret hash must be a SHA-256 hash")
+	}
+
+	toVersion, recipientPubKeyHash := decodeAddress([]byte(to))
+	if !isKeyHashVersion(toVersion) {
+		return nil, errors.New("HTLC recipient must be a wallet address")
+	}
+
+	from := fmt.Sprintf("%s", wallet.GetAddress())
+	htlc := TXOutput{amount, NewHTLCScript(secretHash, recipientPubKeyHash, HashPubKey(wallet.PublicKey), timeout)}
+
+	tx, err := newUnsignedTransaction(from, []TXOutput{htlc}, 0, defaultCoinSelector, 0, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey, SigHashAll); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// NewHTLCClaimTransaction creates a transaction spending an HTLC output to the recipient's wallet
+// by revealing the secret
+func NewHTLCClaimTransaction(wallet *Wallet, txID []byte, vout int, preimage []byte, UTXOSet *UTXOSet) (*Transaction, error) {
+	out, err := findHTLCOutput(txID, vout, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
+	secretHash, recipientPubKeyHash, _, _ := extractHTLC(out.ScriptPubKey)
+
+	hash := sha256.Sum256(preimage)
+	if bytes.Compare(hash[:], secretHash) != 0 {
+		return nil, errors.New("Preimage does not match the secret hash")
+	}
+	if bytes.Compare(HashPubKey(wallet.PublicKey), recipientPubKeyHash) != 0 {
+		return nil, errors.New("Wallet is not the HTLC recipient")
+	}
+
+	return spendHTLCOutput(wallet, txID, vout, out, 0, UTXOSet.Blockchain.chainID, func(signature, pubKey []byte) ([]byte, error) {
+		return NewHTLCClaimScript(signature, pubKey, preimage)
+	})
+}
+
+// NewHTLCRefundTransaction creates a transaction spending an HTLC output back to the sender's wallet.
+// Its lock time is the HTLC timeout, so it can only be mined once the timeout has passed
+func NewHTLCRefundTransaction(wallet *Wallet, txID []byte, vout int, UTXOSet *UTXOSet) (*Transaction, error) {
+	out, err := findHTLCOutput(txID, vout, UTXOSet)
+	if err != nil {
+		return nil, err
+	}
+	_, _, senderPubKeyHash, timeout := extractHTLC(out.ScriptPubKey)
+
+	if bytes.Compare(HashPubKey(wallet.PublicKey), senderPubKeyHash) != 0 {
+		return nil, errors.New("Wallet is not the HTLC sender")
+	}
+
+	return spendHTLCOutput(wallet, txID, vout, out, timeout, UTXOSet.Blockchain.chainID, func(signature, pubKey []byte) ([]byte, error) {
+		return NewHTLCRefundScript(signature, pubKey), nil
+	})
+}
+
+// findHTLCOutput returns an unspent HTLC output
+func findHTLCOutput(txID []byte, vout int, UTXOSet *UTXOSet) (TXOutput, error) {
+	out, err := UTXOSet.FindOutput(txID, vout)
+	if err != nil {
+		return out, err
+	}
+
+	if secretHash, _, _, _ := extractHTLC(out.ScriptPubKey); secretHash == nil {
+		return out, fmt.Errorf("Output %d of transaction %x is not an HTLC", vout, txID)
+	}
+
+	return out, nil
+}
+
+// spendHTLCOutput creates a transaction moving an HTLC output to the wallet, unlocked with
+// the script built by unlock from the wallet's signature for the chain chainID and public key
+func spendHTLCOutput(wallet *Wallet, txID []byte, vout int, out TXOutput, lockTime int64, chainID []byte, unlock func(signature, pubKey []byte) ([]byte, error)) (*Transaction, error) {
+	input := TXInput{txID, vout, nil, sequenceFinal}
+	output := NewTXOutput(out.Value, fmt.Sprintf("%s", wallet.GetAddress()))
+
+	tx := Transaction{nil, []TXInput{input}, []TXOutput{*output}, lockTime}
+	tx.ID = tx.Hash()
+
+	signature := tx.signInput(wallet.PrivateKey, 0, out.ScriptPubKey, SigHashAll, chainID)
+	scriptSig, err := unlock(signature, wallet.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	tx.Vin[0].ScriptSig = scriptSig
+
+	return &tx, nil
+}
@@ -2,17 +2,67 @@ package main
 
 import "bytes"
 
+// Sequence number fields, following BIP 68
+const (
+	// sequenceFinal disables the relative lock time of an input
+	sequenceFinal = 0xffffffff
+	// sequenceDisableFlag is set when the sequence number doesn't encode a relative lock time
+	sequenceDisableFlag = 1 << 31
+	// sequenceTypeFlag is set when the relative lock time is in units of time rather than blocks
+	sequenceTypeFlag = 1 << 22
+	// sequenceMask extracts the relative lock time value
+	sequenceMask = 0x0000ffff
+	// sequenceGranularity is the number of seconds in a unit of time-based relative lock time
+	sequenceGranularity = 512
+)
+
 // TXInput represents a transaction input
 type TXInput struct {
 	Txid      []byte
 	Vout      int
-	Signature []byte
-	PubKey    []byte
+	ScriptSig []byte
+	Sequence  uint32
 }
 
 // UsesKey checks whether the address initiated the transaction
 func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
-	lockingHash := HashPubKey(in.PubKey)
+	pushes := scriptPushes(in.ScriptSig)
+	if len(pushes) != 2 {
+		return false
+	}
+	lockingHash := HashPubKey(pushes[1])
 
 	return bytes.Compare(lockingHash, pubKeyHash) == 0
 }
+
+// RelativeLockBlocks returns a sequence number that locks an input until the output
+// it spends is the given number of blocks deep
+func RelativeLockBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// RelativeLockSeconds returns a sequence number that locks an input until the output
+// it spends is at least the given number of seconds old, rounded up to 512 second units
+func RelativeLockSeconds(seconds uint32) uint32 {
+	units := (seconds + sequenceGranularity - 1) / sequenceGranularity
+	if units > sequenceMask {
+		units = sequenceMask
+	}
+
+	return sequenceTypeFlag | units
+}
+
+// IsRelativeLockSatisfied checks the input's relative lock time against the age of the output it spends,
+// given the height and time of the block containing that output and of the block spending it
+func (in *TXInput) IsRelativeLockSatisfied(prevHeight int, prevTime int64, height int, blockTime int64) bool {
+	if in.Sequence&sequenceDisableFlag != 0 {
+		return true
+	}
+
+	value := int64(in.Sequence & sequenceMask)
+	if in.Sequence&sequenceTypeFlag != 0 {
+		return blockTime-prevTime >= value*sequenceGranularity
+	}
+
+	return int64(height-prevHeight) >= value
+}
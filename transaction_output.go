@@ -6,35 +6,87 @@ import (
 	"log"
 )
 
+// maxDataCarrierSize is the maximum number of bytes a data-carrier output can carry
+const maxDataCarrierSize = 80
+
 // TXOutput represents a transaction output
 type TXOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value        Amount
+	ScriptPubKey []byte
 }
 
-// Lock signs the output
+// Lock locks the output with the standard script for the address
 func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out.PubKeyHash = pubKeyHash
+	out.ScriptPubKey = lockingScript(address)
 }
 
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+	return bytes.Compare(extractPubKeyHash(out.ScriptPubKey), pubKeyHash) == 0
 }
 
 // NewTXOutput create a new TXOutput
-func NewTXOutput(value int, address string) *TXOutput {
+func NewTXOutput(value Amount, address string) *TXOutput {
 	txo := &TXOutput{value, nil}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
-// TXOutputs collects TXOutput
+// NewDataCarrierOutput creates a zero-value output that carries data and can never be spent
+func NewDataCarrierOutput(data []byte) *TXOutput {
+	if len(data) == 0 || len(data) > maxDataCarrierSize {
+		log.Panicf("ERROR: Data must be between 1 and %d bytes", maxDataCarrierSize)
+	}
+
+	return &TXOutput{0, NewDataCarrierScript(data)}
+}
+
+// IsUnspendable checks whether the output can never be spent, which keeps it out of the UTXO set
+func (out *TXOutput) IsUnspendable() bool {
+	return len(out.ScriptPubKey) > 0 && out.ScriptPubKey[0] == OpReturn
+}
+
+// IsValidDataCarrier checks that an unspendable output carries no value and at most
+// maxDataCarrierSize bytes of data
+func (out *TXOutput) IsValidDataCarrier() bool {
+	data := extractDataCarrier(out.ScriptPubKey)
+
+	return out.Value == 0 && data != nil && len(data) <= maxDataCarrierSize
+}
+
+// Serialize serializes a single TXOutput
+func (out TXOutput) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(out)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeOutput deserializes a single TXOutput
+func DeserializeOutput(data []byte) TXOutput {
+	var output TXOutput
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&output)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return output
+}
+
+// TXOutputs collects the unspent outputs of a transaction, keyed by output index,
+// along with the height and time of the block that contains the transaction
 type TXOutputs struct {
-	Outputs []TXOutput
+	Outputs   map[int]TXOutput
+	Height    int
+	Timestamp int64
 }
 
 // Serialize serializes TXOutputs
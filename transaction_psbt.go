@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// psbtMagic starts every serialized PSBT
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// PSBT record types. Every record is a varbytes key, whose first byte is the type, followed by a
+// varbytes value. A record with an empty key ends a map
+const (
+	psbtGlobalUnsignedTx = 0x00
+	psbtGlobalChainID    = 0x01
+
+	psbtInPrevOut        = 0x01
+	psbtInPartialSig     = 0x02
+	psbtInSigHashType    = 0x03
+	psbtInRedeemScript   = 0x04
+	psbtInFinalScriptSig = 0x07
+
+	psbtOutRedeemScript = 0x00
+)
+
+// PSBT is a partially signed transaction: an unsigned transaction together with what its
+// signers need to know about it. Each party adds what it knows, so that a transaction can
+// be signed by several wallets, or by a wallet without access to the blockchain:
+//
+//	creator    wraps an unsigned transaction and the ID of the chain to sign it for
+//	updater    adds the outputs the inputs spend and the redeem scripts of P2SH inputs
+//	signer     adds partial signatures made with its keys
+//	combiner   merges PSBTs signed separately
+//	finalizer  builds the unlocking scripts once an input has enough signatures
+//	extractor  returns the signed transaction
+//
+// Records of unknown types are kept, so that they survive a round trip through this node
+type PSBT struct {
+	Tx      Transaction
+	ChainID []byte
+	Inputs  []PSBTInput
+	Outputs []PSBTOutput
+	Unknown map[string][]byte
+}
+
+// PSBTInput holds what is known about one input of a PSBT
+type PSBTInput struct {
+	PrevOut        *TXOutput
+	RedeemScript   []byte
+	SigHashType    SigHashType
+	PartialSigs    map[string][]byte
+	FinalScriptSig []byte
+	Unknown        map[string][]byte
+}
+
+// PSBTOutput holds what is known about one output of a PSBT
+type PSBTOutput struct {
+	RedeemScript []byte
+	Unknown      map[string][]byte
+}
+
+// IsFinalized checks whether the input's unlocking script has been built
+func (in PSBTInput) IsFinalized() bool {
+	return in.FinalScriptSig != nil
+}
+
+// NewPSBT creates a PSBT for an unsigned transaction, to be signed for the chain chainID
+func NewPSBT(tx Transaction, chainID []byte) (*PSBT, error) {
+	if len(tx.Vin) == 0 || tx.IsCoinbase() {
+		return nil, errors.New("PSBT needs a transaction spending previous outputs")
+	}
+	if len(chainID) == 0 {
+		return nil, errNoChainID
+	}
+
+	for i, vin := range tx.Vin {
+		if len(vin.ScriptSig) != 0 {
+			return nil, fmt.Errorf("Input %d is already signed", i)
+		}
+	}
+
+	p := PSBT{tx, chainID, make([]PSBTInput, len(tx.Vin)), make([]PSBTOutput, len(tx.Vout)), make(map[string][]byte)}
+	for i := range p.Inputs {
+		p.Inputs[i] = PSBTInput{PartialSigs: make(map[string][]byte), Unknown: make(map[string][]byte)}
+	}
+	for i := range p.Outputs {
+		p.Outputs[i] = PSBTOutput{Unknown: make(map[string][]byte)}
+	}
+	p.Tx.ID = p.Tx.Hash()
+
+	return &p, nil
+}
+
+// Update adds the outputs spent by inputs that don't know theirs yet, looked up in prevOuts by
+// outpoint, and the redeem scripts of P2SH inputs and outputs kept in wallets
+func (p *PSBT) Update(prevOuts map[string]TXOutput, wallets *Wallets) {
+	for i, vin := range p.Tx.Vin {
+		in := &p.Inputs[i]
+		if in.IsFinalized() {
+			continue
+		}
+
+		if in.PrevOut == nil {
+			if prevOut, ok := prevOuts[outpoint(vin.Txid, vin.Vout)]; ok {
+				in.PrevOut = &prevOut
+			}
+		}
+
+		if in.PrevOut != nil && in.RedeemScript == nil {
+			in.RedeemScript = wallets.findRedeemScript(in.PrevOut.ScriptPubKey)
+		}
+	}
+
+	for i, vout := range p.Tx.Vout {
+		if p.Outputs[i].RedeemScript == nil {
+			p.Outputs[i].RedeemScript = wallets.findRedeemScript(vout.ScriptPubKey)
+		}
+	}
+}
+
+// findRedeemScript returns the redeem script of a P2SH locking script, if it's in the wallet file
+func (ws Wallets) findRedeemScript(script []byte) []byte {
+	scriptHash := extractScriptHash(script)
+	if scriptHash == nil {
+		return nil
+	}
+
+	for _, redeemScript := range ws.RedeemScripts {
+		if bytes.Equal(HashPubKey(redeemScript), scriptHash) {
+			return redeemScript
+		}
+	}
+
+	return nil
+}
+
+// Sign adds a partial signature for every key in wallets that can unlock a P2PKH or
+// multisig input, bare or wrapped in P2SH. Inputs that require a hash type are signed
+// with it, the others with hashType, which they then require. Signing needs no blockchain:
+// only the previous outputs added by Update. It returns the number of signatures added
+func (p *PSBT) Sign(wallets *Wallets, hashType SigHashType) (int, error) {
+	if len(p.ChainID) == 0 {
+		return 0, errNoChainID
+	}
+
+	signed := 0
+
+	for inID := range p.Tx.Vin {
+		in := &p.Inputs[inID]
+		if in.IsFinalized() || in.PrevOut == nil {
+			continue
+		}
+
+		inHashType := in.SigHashType
+		if inHashType == 0 {
+			inHashType = hashType
+		}
+		if !p.Tx.canSign(inID, inHashType) {
+			continue
+		}
+
+		for _, pubKey := range in.signingKeys(wallets) {
+			if _, ok := in.PartialSigs[hex.EncodeToString(pubKey)]; ok {
+				continue
+			}
+
+			wallet, ok := wallets.GetWalletByPubKeyHash(HashPubKey(pubKey))
+			if !ok || !bytes.Equal(wallet.PublicKey, pubKey) {
+				continue
+			}
+
+			signature := p.Tx.signInput(wallet.PrivateKey, inID, in.PrevOut.ScriptPubKey, inHashType, p.ChainID)
+			in.PartialSigs[hex.EncodeToString(pubKey)] = signature
+			in.SigHashType = inHashType
+			signed++
+		}
+	}
+
+	return signed, nil
+}
+
+// signingKeys returns the public keys that can sign the input. The key of a P2PKH output
+// is only committed to by its hash, so it's looked up in wallets
+func (in PSBTInput) signingKeys(wallets *Wallets) [][]byte {
+	if pubKeyHash := extractPubKeyHash(in.PrevOut.ScriptPubKey); pubKeyHash != nil {
+		if wallet, ok := wallets.GetWalletByPubKeyHash(pubKeyHash); ok {
+			return [][]byte{wallet.PublicKey}
+		}
+		return nil
+	}
+
+	policy := in.PrevOut.ScriptPubKey
+	if extractScriptHash(policy) != nil {
+		policy = in.RedeemScript
+	}
+	_, pubKeys := extractMultisig(policy)
+
+	return pubKeys
+}
+
+// Combine merges the records of other, a PSBT for the same transaction, into p
+func (p *PSBT) Combine(other *PSBT) error {
+	if !bytes.Equal(p.Tx.ID, other.Tx.ID) {
+		return fmt.Errorf("PSBTs are for different transactions %x and %x", p.Tx.ID, other.Tx.ID)
+	}
+	if !bytes.Equal(p.ChainID, other.ChainID) {
+		return fmt.Errorf("PSBTs are for different chains %x and %x", p.ChainID, other.ChainID)
+	}
+
+	for i := range p.Inputs {
+		in, otherIn := &p.Inputs[i], other.Inputs[i]
+
+		if in.PrevOut == nil {
+			in.PrevOut = otherIn.PrevOut
+		}
+		if in.RedeemScript == nil {
+			in.RedeemScript = otherIn.RedeemScript
+		}
+		if in.SigHashType == 0 {
+			in.SigHashType = otherIn.SigHashType
+		}
+		if in.FinalScriptSig == nil {
+			in.FinalScriptSig = otherIn.FinalScriptSig
+		}
+		mergeRecords(in.PartialSigs, otherIn.PartialSigs)
+		mergeRecords(in.Unknown, otherIn.Unknown)
+
+		if in.IsFinalized() {
+			in.clearSigningRecords()
+		}
+	}
+
+	for i := range p.Outputs {
+		if p.Outputs[i].RedeemScript == nil {
+			p.Outputs[i].RedeemScript = other.Outputs[i].RedeemScript
+		}
+		mergeRecords(p.Outputs[i].Unknown, other.Outputs[i].Unknown)
+	}
+
+	mergeRecords(p.Unknown, other.Unknown)
+
+	return nil
+}
+
+// mergeRecords adds the records of src missing from dst
+func mergeRecords(dst, src map[string][]byte) {
+	for key, value := range src {
+		if _, ok := dst[key]; !ok {
+			dst[key] = value
+		}
+	}
+}
+
+// Finalize builds the unlocking script of every input with enough valid signatures and
+// drops the records that are no longer needed. It reports whether all inputs are finalized
+func (p *PSBT) Finalize() bool {
+	complete := true
+
+	for inID := range p.Tx.Vin {
+		in := &p.Inputs[inID]
+		if in.IsFinalized() {
+			continue
+		}
+
+		scriptSig := in.unlockingScript()
+		if scriptSig == nil {
+			complete = false
+			continue
+		}
+
+		txCopy := p.Tx
+		txCopy.Vin = append([]TXInput(nil), p.Tx.Vin...)
+		txCopy.Vin[inID].ScriptSig = scriptSig
+
+		checker := txChecker{&txCopy, inID, in.PrevOut.ScriptPubKey, p.ChainID}
+		if ExecuteScript(scriptSig, in.PrevOut.ScriptPubKey, checker) != nil {
+			complete = false
+			continue
+		}
+
+		in.FinalScriptSig = scriptSig
+		in.clearSigningRecords()
+	}
+
+	return complete
+}
+
+// clearSigningRecords drops the records of a finalized input that only signers need
+func (in *PSBTInput) clearSigningRecords() {
+	in.RedeemScript = nil
+	in.SigHashType = 0
+	in.PartialSigs = make(map[string][]byte)
+}
+
+// unlockingScript builds the unlocking script of an input from its partial signatures,
+// or returns nil when it doesn't have enough of them
+func (in PSBTInput) unlockingScript() []byte {
+	if in.PrevOut == nil {
+		return nil
+	}
+
+	if pubKeyHash := extractPubKeyHash(in.PrevOut.ScriptPubKey); pubKeyHash != nil {
+		for pubKey, signature := range in.PartialSigs {
+			key, _ := hex.DecodeString(pubKey)
+			if bytes.Equal(HashPubKey(key), pubKeyHash) {
+				return NewP2PKHUnlockingScript(signature, key)
+			}
+		}
+
+		return nil
+	}
+
+	policy := in.PrevOut.ScriptPubKey
+	scriptHash := extractScriptHash(policy)
+	if scriptHash != nil {
+		if in.RedeemScript == nil || !bytes.Equal(HashPubKey(in.RedeemScript), scriptHash) {
+			return nil
+		}
+		policy = in.RedeemScript
+	}
+
+	m, pubKeys := extractMultisig(policy)
+	if m == 0 {
+		return nil
+	}
+
+	var signatures [][]byte
+	for _, pubKey := range pubKeys {
+		if signature, ok := in.PartialSigs[hex.EncodeToString(pubKey)]; ok && len(signatures) < m {
+			signatures = append(signatures, signature)
+		}
+	}
+	if len(signatures) < m {
+		return nil
+	}
+
+	scriptSig := NewMultisigUnlockingScript(signatures)
+	if scriptHash != nil {
+		scriptSig = append(scriptSig, pushData(in.RedeemScript)...)
+	}
+
+	return scriptSig
+}
+
+// Extract returns the signed transaction of a finalized PSBT
+func (p *PSBT) Extract() (Transaction, error) {
+	tx := p.Tx
+	tx.Vin = append([]TXInput(nil), p.Tx.Vin...)
+
+	for i, in := range p.Inputs {
+		if !in.IsFinalized() {
+			return tx, fmt.Errorf("Input %d is not finalized", i)
+		}
+		tx.Vin[i].ScriptSig = in.FinalScriptSig
+	}
+	tx.ID = tx.Hash()
+
+	return tx, nil
+}
+
+// Serialize encodes the PSBT: the magic bytes, then the global map holding the unsigned
+// transaction in its canonical encoding and the chain ID, then a map for every input and
+// every output
+func (p *PSBT) Serialize() []byte {
+	var buff bytes.Buffer
+
+	buff.Write(psbtMagic)
+
+	writePSBTRecord(&buff, []byte{psbtGlobalUnsignedTx}, p.Tx.SerializeCanonical())
+	writePSBTRecord(&buff, []byte{psbtGlobalChainID}, p.ChainID)
+	writePSBTUnknown(&buff, p.Unknown)
+	buff.WriteByte(0)
+
+	for _, in := range p.Inputs {
+		if in.PrevOut != nil {
+			var out bytes.Buffer
+			writeUint64(&out, uint64(in.PrevOut.Value))
+			writeVarBytes(&out, in.PrevOut.ScriptPubKey)
+			writePSBTRecord(&buff, []byte{psbtInPrevOut}, out.Bytes())
+		}
+
+		for _, pubKey := range sortedKeys(in.PartialSigs) {
+			key, _ := hex.DecodeString(pubKey)
+			writePSBTRecord(&buff, append([]byte{psbtInPartialSig}, key...), in.PartialSigs[pubKey])
+		}
+
+		if in.SigHashType != 0 {
+			var hashType bytes.Buffer
+			writeUint32(&hashType, uint32(in.SigHashType))
+			writePSBTRecord(&buff, []byte{psbtInSigHashType}, hashType.Bytes())
+		}
+		if in.RedeemScript != nil {
+			writePSBTRecord(&buff, []byte{psbtInRedeemScript}, in.RedeemScript)
+		}
+		if in.FinalScriptSig != nil {
+			writePSBTRecord(&buff, []byte{psbtInFinalScriptSig}, in.FinalScriptSig)
+		}
+
+		writePSBTUnknown(&buff, in.Unknown)
+		buff.WriteByte(0)
+	}
+
+	for _, out := range p.Outputs {
+		if out.RedeemScript != nil {
+			writePSBTRecord(&buff, []byte{psbtOutRedeemScript}, out.RedeemScript)
+		}
+
+		writePSBTUnknown(&buff, out.Unknown)
+		buff.WriteByte(0)
+	}
+
+	return buff.Bytes()
+}
+
+func writePSBTRecord(buff *bytes.Buffer, key, value []byte) {
+	writeVarBytes(buff, key)
+	writeVarBytes(buff, value)
+}
+
+// writePSBTUnknown writes records of unknown types in key order, so that encoding is deterministic
+func writePSBTUnknown(buff *bytes.Buffer, records map[string][]byte) {
+	for _, key := range sortedKeys(records) {
+		writePSBTRecord(buff, []byte(key), records[key])
+	}
+}
+
+func sortedKeys(records map[string][]byte) []string {
+	var keys []string
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// readPSBTMap reads the records of a map up to its terminating empty key
+func readPSBTMap(r *bytes.Reader) (map[string][]byte, error) {
+	records := make(map[string][]byte)
+
+	for {
+		key, err := readVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return records, nil
+		}
+
+		value, err := readVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := records[string(key)]; ok {
+			return nil, fmt.Errorf("Duplicate PSBT key %x", key)
+		}
+		records[string(key)] = value
+	}
+}
+
+// DeserializePSBT decodes a PSBT encoded by Serialize
+func DeserializePSBT(data []byte) (*PSBT, error) {
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, len(psbtMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, psbtMagic) {
+		return nil, errors.New("Not a PSBT")
+	}
+
+	global, err := readPSBTMap(r)
+	if err != nil {
+		return nil, err
+	}
+	txData, ok := global[string([]byte{psbtGlobalUnsignedTx})]
+	if !ok {
+		return nil, errors.New("PSBT has no unsigned transaction")
+	}
+	delete(global, string([]byte{psbtGlobalUnsignedTx}))
+	chainID, ok := global[string([]byte{psbtGlobalChainID})]
+	if !ok {
+		return nil, errors.New("PSBT has no chain ID")
+	}
+	delete(global, string([]byte{psbtGlobalChainID}))
+
+	tx, err := DeserializeCanonical(txData)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPSBT(tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	p.Unknown = global
+
+	for i := range p.Inputs {
+		records, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Inputs[i].decode(records); err != nil {
+			return nil, fmt.Errorf("Input %d: %s", i, err)
+		}
+	}
+
+	for i := range p.Outputs {
+		records, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+
+		out := &p.Outputs[i]
+		for key, value := range records {
+			if key == string([]byte{psbtOutRedeemScript}) {
+				out.RedeemScript = value
+			} else {
+				out.Unknown[key] = value
+			}
+		}
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after PSBT", r.Len())
+	}
+
+	return p, nil
+}
+
+// decode fills the input from its records
+func (in *PSBTInput) decode(records map[string][]byte) error {
+	for key, value := range records {
+		switch {
+		case key == string([]byte{psbtInPrevOut}):
+			r := bytes.NewReader(value)
+			amount, err := readUint64(r)
+			if err != nil {
+				return err
+			}
+			script, err := readVarBytes(r)
+			if err != nil {
+				return err
+			}
+			in.PrevOut = &TXOutput{Amount(int64(amount)), script}
+		case key[0] == psbtInPartialSig && len(key) > 1:
+			in.PartialSigs[hex.EncodeToString([]byte(key[1:]))] = value
+		case key == string([]byte{psbtInSigHashType}):
+			hashType, err := readUint32(bytes.NewReader(value))
+			if err != nil {
+				return err
+			}
+			in.SigHashType = SigHashType(hashType)
+			if hashType > 0xff || !in.SigHashType.IsValid() {
+				return fmt.Errorf("Invalid signature hash type %d", hashType)
+			}
+		case key == string([]byte{psbtInRedeemScript}):
+			in.RedeemScript = value
+		case key == string([]byte{psbtInFinalScriptSig}):
+			in.FinalScriptSig = value
+		default:
+			in.Unknown[key] = value
+		}
+	}
+
+	return nil
+}
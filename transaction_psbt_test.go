@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPSBTMultisig(t *testing.T) {
+	alice := Wallets{make(map[string]*Wallet), make(map[string][]byte)}
+	bob := Wallets{make(map[string]*Wallet), make(map[string][]byte)}
+	aliceAddress := alice.CreateWallet(SchemeP256)
+	bobAddress := bob.CreateWallet(SchemeSchnorr)
+
+	redeemScript := NewMultisigScript(2, [][]byte{alice.Wallets[aliceAddress].PublicKey, bob.Wallets[bobAddress].PublicKey})
+	multisigAddress := alice.AddRedeemScript(redeemScript)
+	bob.AddRedeemScript(redeemScript)
+
+	tx := Transaction{
+		Vin:  []TXInput{{[]byte("tx 1"), 0, nil, sequenceFinal}, {[]byte("tx 2"), 1, nil, sequenceFinal}},
+		Vout: []TXOutput{*NewTXOutput(7, bobAddress)},
+	}
+	prevOuts := map[string]TXOutput{
+		outpoint([]byte("tx 1"), 0): *NewTXOutput(4, multisigAddress),
+		outpoint([]byte("tx 2"), 1): *NewTXOutput(4, aliceAddress),
+	}
+
+	p, err := NewPSBT(tx, testChainID)
+	assert.Nil(t, err)
+	p.Update(prevOuts, &alice)
+	assert.Equal(t, redeemScript, p.Inputs[0].RedeemScript)
+	p.Unknown["\xf0custom"] = []byte("kept")
+
+	// Both parties sign their own copy, without a blockchain
+	aliceCopy, err := DeserializePSBT(p.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, p.Serialize(), aliceCopy.Serialize())
+	assert.Equal(t, testChainID, aliceCopy.ChainID, "The PSBT carries the chain to sign for")
+	bobCopy, _ := DeserializePSBT(p.Serialize())
+	signed, err := aliceCopy.Sign(&alice, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, signed)
+	signed, err = bobCopy.Sign(&bob, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+
+	assert.False(t, bobCopy.Finalize(), "One signature doesn't unlock the multisig input")
+	_, err = bobCopy.Extract()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, aliceCopy.Combine(bobCopy))
+	assert.True(t, aliceCopy.Finalize())
+	assert.Empty(t, aliceCopy.Inputs[0].PartialSigs)
+
+	signedTx, err := aliceCopy.Extract()
+	assert.Nil(t, err)
+	assert.Equal(t, tx.Hash(), signedTx.ID, "Signing doesn't change the ID")
+	for inID, vin := range signedTx.Vin {
+		prevOut := prevOuts[outpoint(vin.Txid, vin.Vout)]
+		assert.Nil(t, ExecuteScript(vin.ScriptSig, prevOut.ScriptPubKey, txChecker{&signedTx, inID, prevOut.ScriptPubKey, testChainID}))
+	}
+
+	decoded, err := DeserializePSBT(aliceCopy.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("kept"), decoded.Unknown["\xf0custom"])
+
+	other, _ := NewPSBT(Transaction{Vin: []TXInput{{[]byte("tx 3"), 0, nil, sequenceFinal}}}, testChainID)
+	assert.NotNil(t, aliceCopy.Combine(other), "PSBTs of different transactions can't be combined")
+	other, _ = NewPSBT(tx, []byte("other chain"))
+	assert.NotNil(t, aliceCopy.Combine(other), "PSBTs for different chains can't be combined")
+
+	_, err = NewPSBT(signedTx, testChainID)
+	assert.NotNil(t, err)
+	_, err = NewPSBT(tx, nil)
+	assert.Equal(t, errNoChainID, err, "A PSBT needs the chain to sign for")
+	_, err = DeserializePSBT(append(p.Serialize(), 0))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+)
+
+// outpoint identifies a transaction output as "txid:vout"
+func outpoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// SignRaw signs every input spending a P2PKH output of a key in wallets. prevOuts maps the
+// outpoint of each input to the output it spends, and chainID is the chain to sign for, so
+// that signing needs no blockchain. It reports whether all inputs are now signed and valid
+func (tx *Transaction) SignRaw(wallets *Wallets, prevOuts map[string]TXOutput, hashType SigHashType, chainID []byte) (bool, error) {
+	if len(chainID) == 0 {
+		return false, errNoChainID
+	}
+
+	complete := true
+
+	for inID, vin := range tx.Vin {
+		prevOut, ok := prevOuts[outpoint(vin.Txid, vin.Vout)]
+		if !ok {
+			complete = false
+			continue
+		}
+
+		if pubKeyHash := extractPubKeyHash(prevOut.ScriptPubKey); pubKeyHash != nil {
+			if wallet, ok := wallets.GetWalletByPubKeyHash(pubKeyHash); ok && tx.canSign(inID, hashType) {
+				signature := tx.signInput(wallet.PrivateKey, inID, prevOut.ScriptPubKey, hashType, chainID)
+				tx.Vin[inID].ScriptSig = NewP2PKHUnlockingScript(signature, wallet.PublicKey)
+			}
+		}
+
+		checker := txChecker{tx, inID, prevOut.ScriptPubKey, chainID}
+		if ExecuteScript(tx.Vin[inID].ScriptSig, prevOut.ScriptPubKey, checker) != nil {
+			complete = false
+		}
+	}
+
+	return complete, nil
+}
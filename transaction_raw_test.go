@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeserializeCanonical(t *testing.T) {
+	tx := Transaction{
+		nil,
+		[]TXInput{{[]byte("tx 1"), 3, []byte{1, 2}, 7}, {[]byte("tx 2"), -1, nil, sequenceFinal}},
+		[]TXOutput{{5, []byte{OpDup}}, {0, NewDataCarrierScript([]byte("data"))}},
+		600000000,
+	}
+	tx.ID = tx.Hash()
+
+	decoded, err := DeserializeCanonical(tx.SerializeCanonical())
+	assert.Nil(t, err)
+	assert.Equal(t, tx.SerializeCanonical(), decoded.SerializeCanonical())
+	assert.Equal(t, tx.ID, decoded.ID)
+
+	_, err = DeserializeCanonical(append(tx.SerializeCanonical(), 0))
+	assert.NotNil(t, err, "Trailing bytes are rejected")
+	_, err = DeserializeCanonical(tx.SerializeCanonical()[:20])
+	assert.NotNil(t, err)
+	_, err = DeserializeCanonical([]byte{0xfd, 0x01, 0x00})
+	assert.Equal(t, errNonCanonicalVarInt, err)
+}
+
+func TestSignRaw(t *testing.T) {
+	wallets := Wallets{make(map[string]*Wallet), make(map[string][]byte)}
+	address := wallets.CreateWallet(SchemeSecp256k1)
+	other := NewWallet(SchemeP256)
+
+	tx := &Transaction{
+		Vin:  []TXInput{{[]byte("tx 1"), 0, nil, sequenceFinal}, {[]byte("tx 2"), 1, nil, sequenceFinal}},
+		Vout: []TXOutput{*NewTXOutput(5, address)},
+	}
+	prevOuts := map[string]TXOutput{
+		outpoint([]byte("tx 1"), 0): *NewTXOutput(3, address),
+		outpoint([]byte("tx 2"), 1): *NewTXOutput(3, string(other.GetAddress())),
+	}
+
+	_, err := tx.SignRaw(&wallets, prevOuts, SigHashAll, nil)
+	assert.Equal(t, errNoChainID, err, "Signing needs a chain ID")
+	assert.Nil(t, tx.Vin[0].ScriptSig)
+
+	complete, err := tx.SignRaw(&wallets, prevOuts, SigHashAll, testChainID)
+	assert.Nil(t, err)
+	assert.False(t, complete, "Inputs of other keys stay unsigned")
+	assert.NotNil(t, tx.Vin[0].ScriptSig)
+	assert.Nil(t, tx.Vin[1].ScriptSig)
+
+	wallets.Wallets[string(other.GetAddress())] = other
+	complete, err = tx.SignRaw(&wallets, prevOuts, SigHashAll, testChainID)
+	assert.Nil(t, err)
+	assert.True(t, complete)
+
+	hashType, err := ParseSigHashType("single|anyonecanpay")
+	assert.Nil(t, err)
+	assert.Equal(t, SigHashSingle|SigHashAnyoneCanPay, hashType)
+	_, err = ParseSigHashType("EVERYTHING")
+	assert.NotNil(t, err)
+}
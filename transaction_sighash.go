@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// SigHashType selects the parts of a transaction a signature commits to.
+// It is appended to every signature as a single byte
+type SigHashType byte
+
+// Signature hash types
+const (
+	// SigHashAll commits to all inputs and outputs
+	SigHashAll SigHashType = 0x01
+	// SigHashNone commits to all inputs and none of the outputs
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle commits to all inputs and the output with the same index as the signed input
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyoneCanPay modifies the other types to commit to the signed input only,
+	// so that anyone can add inputs
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+// errNoChainID is returned when signing without the chain that signatures are made for
+var errNoChainID = errors.New("Chain ID is not set")
+
+var sigHashTypeNames = map[string]SigHashType{
+	"ALL":    SigHashAll,
+	"NONE":   SigHashNone,
+	"SINGLE": SigHashSingle,
+}
+
+// ParseSigHashType parses a hash type name: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY
+func ParseSigHashType(name string) (SigHashType, error) {
+	base := strings.ToUpper(name)
+	anyoneCanPay := strings.HasSuffix(base, "|ANYONECANPAY")
+	base = strings.TrimSuffix(base, "|ANYONECANPAY")
+
+	hashType, ok := sigHashTypeNames[base]
+	if !ok {
+		return 0, fmt.Errorf("Unknown signature hash type %q", name)
+	}
+	if anyoneCanPay {
+		hashType |= SigHashAnyoneCanPay
+	}
+
+	return hashType, nil
+}
+
+// IsValid checks whether the hash type is one of the defined types, optionally with ANYONECANPAY
+func (hashType SigHashType) IsValid() bool {
+	base := hashType &^ SigHashAnyoneCanPay
+
+	return base >= SigHashAll && base <= SigHashSingle
+}
+
+// canSign checks whether the input at inID can be signed with hashType: SIGHASH_SINGLE
+// needs an output with the input's index
+func (tx *Transaction) canSign(inID int, hashType SigHashType) bool {
+	return hashType&^SigHashAnyoneCanPay != SigHashSingle || inID < len(tx.Vout)
+}
+
+// signInput signs the input at inID spending an output locked with prevScript for the chain
+// chainID, and appends hashType
+func (tx *Transaction) signInput(privKey PrivateKey, inID int, prevScript []byte, hashType SigHashType, chainID []byte) []byte {
+	if len(chainID) == 0 {
+		log.Panic(errNoChainID)
+	}
+
+	dataToSign := tx.signatureDigest(inID, prevScript, hashType, chainID)
+	if dataToSign == nil {
+		log.Panicf("ERROR: Input %d has no output to sign with SIGHASH_SINGLE", inID)
+	}
+
+	return append(privKey.Sign(dataToSign), byte(hashType))
+}
+
+// signatureDigest returns the data signed by the input at inID spending an output locked with prevScript:
+// the SHA-256 hash of the input's sighash preimage. chainID, the genesis block hash of the chain
+// the signature is made for, is committed to, so that a transaction signed for one chain can't be
+// replayed on another chain sharing its history. It returns nil when chainID is empty, and for
+// SIGHASH_SINGLE when there is no output with the input's index
+func (tx *Transaction) signatureDigest(inID int, prevScript []byte, hashType SigHashType, chainID []byte) []byte {
+	if len(chainID) == 0 {
+		return nil
+	}
+
+	preimage := tx.sighashPreimage(inID, prevScript, hashType, chainID)
+	if preimage == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256(preimage)
+
+	return hash[:]
+}
+
+// sighashPreimage serializes the parts of the transaction selected by hashType, with the
+// input at inID carrying prevScript and every other input an empty script:
+//
+//	varint  number of inputs
+//	        for each input: varbytes txid, uint32 output index, varbytes script, uint32 sequence
+//	varint  number of outputs
+//	        for each output: int64 value, varbytes script
+//	int64   lock time
+//	uint32  hash type
+//	varbytes chain ID
+//
+// Integers are little-endian, varint is Bitcoin's CompactSize and varbytes is a varint length
+// followed by the bytes
+func (tx *Transaction) sighashPreimage(inID int, prevScript []byte, hashType SigHashType, chainID []byte) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].ScriptSig = prevScript
+
+	switch hashType &^ SigHashAnyoneCanPay {
+	case SigHashNone:
+		txCopy.Vout = nil
+	case SigHashSingle:
+		if inID >= len(txCopy.Vout) {
+			return nil
+		}
+
+		txCopy.Vout = txCopy.Vout[:inID+1]
+		for i := 0; i < inID; i++ {
+			txCopy.Vout[i] = TXOutput{-1, nil}
+		}
+	}
+
+	// Other inputs' sequence numbers are only committed to along with the outputs,
+	// so that they can still be updated
+	if hashType&^SigHashAnyoneCanPay != SigHashAll {
+		for i := range txCopy.Vin {
+			if i != inID {
+				txCopy.Vin[i].Sequence = 0
+			}
+		}
+	}
+
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txCopy.Vin = txCopy.Vin[inID : inID+1]
+	}
+
+	var buff bytes.Buffer
+
+	writeVarInt(&buff, uint64(len(txCopy.Vin)))
+	for _, vin := range txCopy.Vin {
+		writeVarBytes(&buff, vin.Txid)
+		writeUint32(&buff, uint32(vin.Vout))
+		writeVarBytes(&buff, vin.ScriptSig)
+		writeUint32(&buff, vin.Sequence)
+	}
+
+	writeVarInt(&buff, uint64(len(txCopy.Vout)))
+	for _, vout := range txCopy.Vout {
+		writeUint64(&buff, uint64(vout.Value))
+		writeVarBytes(&buff, vout.ScriptPubKey)
+	}
+
+	writeUint64(&buff, uint64(txCopy.LockTime))
+	writeUint32(&buff, uint32(hashType))
+	writeVarBytes(&buff, chainID)
+
+	return buff.Bytes()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testChainID is the chain that test transactions are signed for
+var testChainID = []byte("test chain")
+
+func TestIsFinal(t *testing.T) {
+	tx := Transaction{LockTime: 0}
+	assert.True(t, tx.IsFinal(0, 0), "Zero lock time is always final")
+
+	tx.LockTime = 10
+	assert.False(t, tx.IsFinal(10, 1600000000), "Height lock is not final at the lock height")
+	assert.True(t, tx.IsFinal(11, 0), "Height lock is final after the lock height")
+
+	tx.LockTime = 1600000000
+	assert.False(t, tx.IsFinal(1000000, 1600000000), "Time lock ignores height")
+	assert.True(t, tx.IsFinal(0, 1600000001), "Time lock is final after the lock time")
+}
+
+func TestRelativeLock(t *testing.T) {
+	in := TXInput{Sequence: sequenceFinal}
+	assert.True(t, in.IsRelativeLockSatisfied(10, 0, 10, 0), "Disabled relative lock is always satisfied")
+
+	in.Sequence = RelativeLockBlocks(5)
+	assert.False(t, in.IsRelativeLockSatisfied(10, 0, 14, 0))
+	assert.True(t, in.IsRelativeLockSatisfied(10, 0, 15, 0))
+
+	in.Sequence = RelativeLockSeconds(1000)
+	assert.Equal(t, uint32(sequenceTypeFlag|2), in.Sequence, "Seconds are rounded up to 512 second units")
+	assert.False(t, in.IsRelativeLockSatisfied(10, 1000, 100, 2023))
+	assert.True(t, in.IsRelativeLockSatisfied(10, 1000, 11, 2024))
+}
+
+func TestCheckSequence(t *testing.T) {
+	tx := &Transaction{Vin: []TXInput{{Sequence: RelativeLockBlocks(10)}}}
+	checker := txChecker{tx, 0, nil, testChainID}
+
+	assert.True(t, checker.CheckSequence(int64(RelativeLockBlocks(10))))
+	assert.False(t, checker.CheckSequence(int64(RelativeLockBlocks(11))), "Input lock must be at least as long as required")
+	assert.False(t, checker.CheckSequence(int64(RelativeLockSeconds(512))), "Lock types must match")
+
+	csv := append(pushInt(int64(RelativeLockBlocks(10))), OpCheckSequenceVerify, OpDrop, Op1)
+	assert.Nil(t, ExecuteScript(nil, csv, checker))
+
+	tx.Vin[0].Sequence = sequenceFinal
+	assert.NotNil(t, ExecuteScript(nil, csv, checker), "Disabled input lock fails OP_CHECKSEQUENCEVERIFY")
+}
+
+func TestCheckLockTime(t *testing.T) {
+	tx := &Transaction{Vin: []TXInput{{Sequence: sequenceFinal}}, LockTime: 100}
+	checker := txChecker{tx, 0, nil, testChainID}
+
+	assert.True(t, checker.CheckLockTime(100))
+	assert.False(t, checker.CheckLockTime(101), "Transaction lock time must be at least as late as required")
+	assert.False(t, checker.CheckLockTime(1600000000), "Lock time kinds must match")
+}
+
+func TestSignatureHashTypes(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	pubKey := privKey.PublicKey()
+	prevScript := NewP2PKHScript(HashPubKey(pubKey))
+
+	newTx := func() *Transaction {
+		return &Transaction{
+			Vin:  []TXInput{{[]byte("tx 1"), 0, nil, sequenceFinal}, {[]byte("tx 2"), 0, nil, sequenceFinal}},
+			Vout: []TXOutput{{5, []byte("output 0")}, {7, []byte("output 1")}},
+		}
+	}
+	verify := func(tx *Transaction, signature []byte) bool {
+		return txChecker{tx, 0, prevScript, testChainID}.CheckSig(signature, pubKey)
+	}
+
+	tx := newTx()
+	signature := tx.signInput(privKey, 0, prevScript, SigHashAll, testChainID)
+	assert.Equal(t, byte(SigHashAll), signature[len(signature)-1])
+	assert.True(t, verify(tx, signature))
+	tx.Vout[1].Value = 8
+	assert.False(t, verify(tx, signature), "ALL commits to every output")
+
+	tx = newTx()
+	signature = tx.signInput(privKey, 0, prevScript, SigHashNone, testChainID)
+	tx.Vout[0].Value = 1
+	assert.True(t, verify(tx, signature), "NONE commits to no outputs")
+	tx.Vin[1].Vout = 1
+	assert.False(t, verify(tx, signature), "NONE commits to every input")
+
+	tx = newTx()
+	signature = tx.signInput(privKey, 0, prevScript, SigHashSingle, testChainID)
+	tx.Vout[1].Value = 1
+	assert.True(t, verify(tx, signature), "SINGLE ignores other outputs")
+	tx.Vout[0].Value = 1
+	assert.False(t, verify(tx, signature), "SINGLE commits to the matching output")
+
+	tx = newTx()
+	signature = tx.signInput(privKey, 0, prevScript, SigHashAll|SigHashAnyoneCanPay, testChainID)
+	tx.Vin = append(tx.Vin, TXInput{[]byte("tx 3"), 0, nil, sequenceFinal})
+	tx.ID = tx.Hash()
+	assert.True(t, verify(tx, signature), "ANYONECANPAY lets anyone add inputs")
+	tx.Vout[0].Value = 1
+	assert.False(t, verify(tx, signature))
+
+	signature[len(signature)-1] = 0x04
+	assert.False(t, verify(tx, signature), "Unknown hash types are rejected")
+}
+
+func TestSighashPreimage(t *testing.T) {
+	tx := &Transaction{
+		Vin:      []TXInput{{[]byte{0xaa, 0xbb}, 1, []byte{0x01}, sequenceFinal}},
+		Vout:     []TXOutput{{5, []byte{0x51}}},
+		LockTime: 7,
+	}
+
+	expected, _ := hex.DecodeString(
+		"01" + "02aabb" + "01000000" + "02cafe" + "ffffffff" +
+			"01" + "0500000000000000" + "0151" +
+			"0700000000000000" + "01000000" + "02c11d")
+	assert.Equal(t, expected, tx.sighashPreimage(0, []byte{0xca, 0xfe}, SigHashAll, []byte{0xc1, 0x1d}))
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	pubKey := privKey.PublicKey()
+	digest := sha256.Sum256([]byte("data"))
+
+	assert.Len(t, pubKey, 33, "Public keys are SEC1 compressed")
+	ecdsaKey := privKey.ecdsaKey()
+	uncompressed := elliptic.Marshal(ecdsaKey.Curve, ecdsaKey.X, ecdsaKey.Y)
+
+	signature := privKey.Sign(digest[:])
+	assert.Len(t, signature, 64)
+	assert.True(t, verifySignature(pubKey, signature, digest[:]))
+	assert.True(t, verifySignature(uncompressed, signature, digest[:]))
+	assert.True(t, verifySignature(pubKey, encodeSignatureDER(signature), digest[:]))
+
+	nonCanonical := append(encodeSignatureDER(signature), 0x00)
+	assert.False(t, verifySignature(pubKey, nonCanonical, digest[:]), "Trailing bytes are rejected")
+	assert.False(t, verifySignature(pubKey[1:], signature, digest[:]), "Raw coordinates are rejected")
+}
+
+func TestTransactionHash(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	pubKey := privKey.PublicKey()
+	prevScript := NewP2PKHScript(HashPubKey(pubKey))
+
+	tx := &Transaction{
+		Vin:  []TXInput{{[]byte("tx 1"), 0, nil, sequenceFinal}},
+		Vout: []TXOutput{{5, prevScript}},
+	}
+	signature := tx.signInput(privKey, 0, prevScript, SigHashAll, testChainID)
+	tx.Vin[0].ScriptSig = NewP2PKHUnlockingScript(signature, pubKey)
+	id, witnessHash := tx.Hash(), tx.WitnessHash()
+
+	der := append(encodeSignatureDER(signature[:len(signature)-1]), byte(SigHashAll))
+	tx.Vin[0].ScriptSig = NewP2PKHUnlockingScript(der, pubKey)
+	assert.Equal(t, id, tx.Hash(), "Re-encoding a signature keeps the ID")
+	assert.NotEqual(t, witnessHash, tx.WitnessHash())
+	assert.True(t, txChecker{tx, 0, prevScript, testChainID}.CheckSig(der, pubKey))
+
+	tx.Vout[0].Value = 6
+	assert.NotEqual(t, id, tx.Hash())
+
+	coinbase1 := NewCoinbaseTX(string(encodeAddress(version, HashPubKey(pubKey))), "")
+	coinbase2 := NewCoinbaseTX(string(encodeAddress(version, HashPubKey(pubKey))), "")
+	assert.NotEqual(t, coinbase1.ID, coinbase2.ID, "Coinbase IDs commit to their input script")
+}
+
+func TestDustChange(t *testing.T) {
+	wallet := NewWallet(SchemeP256)
+	from, to := string(wallet.GetAddress()), string(NewWallet(SchemeP256).GetAddress())
+	UTXOSet := UTXOSet{newTestBlockchain(t, wallet)}
+	threshold := dustThreshold(*NewTXOutput(0, from))
+
+	tx, err := NewUTXOTransaction(wallet, to, subsidy-threshold+1, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+	assert.Nil(t, err)
+	assert.Equal(t, []TXOutput{*NewTXOutput(subsidy-threshold+1, to)}, tx.Vout, "Change below the dust threshold goes to the fee")
+
+	tx, err = NewUTXOTransaction(wallet, to, subsidy-threshold, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+	assert.Nil(t, err)
+	assert.Equal(t, []TXOutput{*NewTXOutput(subsidy-threshold, to), *NewTXOutput(threshold, from)}, tx.Vout)
+}
+
+func TestVerifyErrors(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	prevTx := Transaction{[]byte("prev"), nil, []TXOutput{{5, NewP2PKHScript(HashPubKey(privKey.PublicKey()))}}, 0}
+	prevTXs := map[string]Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+
+	tx := &Transaction{
+		Vin:  []TXInput{{[]byte("missing"), 0, nil, sequenceFinal}},
+		Vout: []TXOutput{{5, prevTx.Vout[0].ScriptPubKey}},
+	}
+	assert.Equal(t, TxNotFoundError{[]byte("missing")}, tx.Sign(privKey, SigHashAll, prevTXs, testChainID))
+	assert.Equal(t, TxNotFoundError{[]byte("missing")}, tx.Verify(prevTXs, testChainID))
+
+	tx.Vin[0] = TXInput{prevTx.ID, 1, nil, sequenceFinal}
+	err := tx.Verify(prevTXs, testChainID)
+	assert.IsType(t, ValidationError{}, err, "Spending an output that doesn't exist doesn't panic")
+	assert.Equal(t, "bad-outpoint", err.(ValidationError).Reason)
+
+	tx.Vin[0].Vout = 0
+	assert.Equal(t, "bad-signature", tx.Verify(prevTXs, testChainID).(ValidationError).Reason)
+	assert.Nil(t, tx.Sign(privKey, SigHashAll, prevTXs, testChainID))
+	assert.Nil(t, tx.Verify(prevTXs, testChainID))
+
+	_, err = DeserializeTransaction([]byte("not a transaction"))
+	assert.NotNil(t, err)
+}
+
+func TestChainIDReplay(t *testing.T) {
+	privKey := NewPrivateKey(SchemeP256)
+	prevTx := Transaction{[]byte("prev"), nil, []TXOutput{{5, NewP2PKHScript(HashPubKey(privKey.PublicKey()))}}, 0}
+	prevTXs := map[string]Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+	tx := &Transaction{
+		Vin:  []TXInput{{prevTx.ID, 0, nil, sequenceFinal}},
+		Vout: []TXOutput{{5, prevTx.Vout[0].ScriptPubKey}},
+	}
+
+	assert.Equal(t, errNoChainID, tx.Sign(privKey, SigHashAll, prevTXs, nil), "Signing needs a chain ID")
+
+	assert.Nil(t, tx.Sign(privKey, SigHashAll, prevTXs, []byte("chain 1")))
+	assert.Nil(t, tx.Verify(prevTXs, []byte("chain 1")))
+	assert.NotNil(t, tx.Verify(prevTXs, []byte("chain 2")), "Signatures for one chain are invalid on another")
+	assert.NotNil(t, tx.Verify(prevTXs, nil))
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"io"
 	"log"
 )
 
@@ -23,3 +25,134 @@ func ReverseBytes(data []byte) {
 		data[i], data[j] = data[j], data[i]
 	}
 }
+
+// writeUint32 writes a little-endian uint32
+func writeUint32(buff *bytes.Buffer, n uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], n)
+	buff.Write(b[:])
+}
+
+// writeUint64 writes a little-endian uint64
+func writeUint64(buff *bytes.Buffer, n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	buff.Write(b[:])
+}
+
+// writeVarInt writes an unsigned integer in Bitcoin's CompactSize encoding
+func writeVarInt(buff *bytes.Buffer, n uint64) {
+	switch {
+	case n < 0xfd:
+		buff.WriteByte(byte(n))
+	case n <= 0xffff:
+		buff.WriteByte(0xfd)
+		buff.Write([]byte{byte(n), byte(n >> 8)})
+	case n <= 0xffffffff:
+		buff.WriteByte(0xfe)
+		writeUint32(buff, uint32(n))
+	default:
+		buff.WriteByte(0xff)
+		writeUint64(buff, n)
+	}
+}
+
+// varIntSize returns the length of the CompactSize encoding of n
+func varIntSize(n uint64) int {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= 0xffff:
+		return 3
+	case n <= 0xffffffff:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// writeVarBytes writes a byte slice prefixed with its CompactSize length
+func writeVarBytes(buff *bytes.Buffer, data []byte) {
+	writeVarInt(buff, uint64(len(data)))
+	buff.Write(data)
+}
+
+// errNonCanonicalVarInt is returned when a CompactSize integer isn't encoded in the fewest bytes
+var errNonCanonicalVarInt = errors.New("Non-canonical varint")
+
+// readUint32 reads a little-endian uint32
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+// readUint64 reads a little-endian uint64
+func readUint64(r *bytes.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+// readVarInt reads an unsigned integer in Bitcoin's CompactSize encoding. Only the
+// shortest encoding of a number is accepted, so that every value has one encoding
+func readVarInt(r *bytes.Reader) (uint64, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var n, min uint64
+	switch prefix {
+	case 0xfd:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		n, min = uint64(binary.LittleEndian.Uint16(b[:])), 0xfd
+	case 0xfe:
+		v, err := readUint32(r)
+		if err != nil {
+			return 0, err
+		}
+		n, min = uint64(v), 0x10000
+	case 0xff:
+		v, err := readUint64(r)
+		if err != nil {
+			return 0, err
+		}
+		n, min = v, 0x100000000
+	default:
+		return uint64(prefix), nil
+	}
+
+	if n < min {
+		return 0, errNonCanonicalVarInt
+	}
+
+	return n, nil
+}
+
+// readVarBytes reads a byte slice prefixed with its CompactSize length
+func readVarBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -1,39 +1,44 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"log"
 
 	"github.com/boltdb/bolt"
 )
 
 const utxoBucket = "chainstate"
+const addrIndexBucket = "addrindex"
+
+// chainstateVersion is the format of the chainstate and the address index. It's saved
+// in the blocks bucket, and databases saved in another format are reindexed when opened
+const chainstateVersion = 2
+const chainstateVersionKey = "v"
 
 // UTXOSet represents UTXO set
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs
-func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
-	unspentOutputs := make(map[string][]int)
-	accumulated := 0
+// FindCoins finds the unspent outputs locked with the script, as coins to choose inputs from
+func (u UTXOSet) FindCoins(lockingScript []byte) []Coin {
+	var coins []Coin
 	db := u.Blockchain.db
+	prefix := addrIndexPrefix(lockingScript)
 
 	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+		b := tx.Bucket([]byte(addrIndexBucket))
 		c := b.Cursor()
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			txID := hex.EncodeToString(k)
-			outs := DeserializeOutputs(v)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			txID, outIdx := parseAddrIndexKey(k)
+			out := DeserializeOutput(v)
 
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
-				}
-			}
+			coins = append(coins, Coin{append([]byte{}, txID...), outIdx, out.Value})
 		}
 
 		return nil
@@ -42,26 +47,21 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 		log.Panic(err)
 	}
 
-	return accumulated, unspentOutputs
+	return coins
 }
 
-// FindUTXO finds UTXO for a public key hash
-func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
+// FindUTXO finds UTXO locked with a script
+func (u UTXOSet) FindUTXO(lockingScript []byte) []TXOutput {
 	var UTXOs []TXOutput
 	db := u.Blockchain.db
+	prefix := addrIndexPrefix(lockingScript)
 
 	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+		b := tx.Bucket([]byte(addrIndexBucket))
 		c := b.Cursor()
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			outs := DeserializeOutputs(v)
-
-			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
-					UTXOs = append(UTXOs, out)
-				}
-			}
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			UTXOs = append(UTXOs, DeserializeOutput(v))
 		}
 
 		return nil
@@ -73,6 +73,35 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	return UTXOs
 }
 
+// FindOutput returns an unspent output by its transaction ID and index
+func (u UTXOSet) FindOutput(txID []byte, outIdx int) (TXOutput, error) {
+	var out TXOutput
+	found := false
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+
+		outsBytes := b.Get(txID)
+		if outsBytes == nil {
+			return nil
+		}
+
+		out, found = DeserializeOutputs(outsBytes).Outputs[outIdx]
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !found {
+		return out, errors.New("Output is not found or already spent")
+	}
+
+	return out, nil
+}
+
 // CountTransactions returns the number of transactions in the UTXO set
 func (u UTXOSet) CountTransactions() int {
 	db := u.Blockchain.db
@@ -95,20 +124,62 @@ func (u UTXOSet) CountTransactions() int {
 	return counter
 }
 
-// Reindex rebuilds the UTXO set
+// CheckSequenceLocks checks that the relative lock times of all inputs of a transaction
+// are satisfied in a block at the given height and time. An output that isn't in the UTXO
+// set, such as one of an unconfirmed transaction, would be confirmed in the same block
+func (u UTXOSet) CheckSequenceLocks(tx *Transaction, height int, blockTime int64) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	satisfied := true
+	db := u.Blockchain.db
+
+	err := db.View(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+
+		for _, vin := range tx.Vin {
+			if vin.Sequence&sequenceDisableFlag != 0 {
+				continue
+			}
+
+			prevHeight, prevTime := height, blockTime
+			if outsBytes := b.Get(vin.Txid); outsBytes != nil {
+				outs := DeserializeOutputs(outsBytes)
+				prevHeight, prevTime = outs.Height, outs.Timestamp
+			}
+
+			if !vin.IsRelativeLockSatisfied(prevHeight, prevTime, height, blockTime) {
+				satisfied = false
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return satisfied
+}
+
+// Reindex rebuilds the UTXO set and the address index
 func (u UTXOSet) Reindex() {
 	db := u.Blockchain.db
-	bucketName := []byte(utxoBucket)
+	bucketNames := [][]byte{[]byte(utxoBucket), []byte(addrIndexBucket)}
 
 	err := db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket(bucketName)
-		if err != nil && err != bolt.ErrBucketNotFound {
-			log.Panic(err)
-		}
+		for _, bucketName := range bucketNames {
+			err := tx.DeleteBucket(bucketName)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				log.Panic(err)
+			}
 
-		_, err = tx.CreateBucket(bucketName)
-		if err != nil {
-			log.Panic(err)
+			_, err = tx.CreateBucket(bucketName)
+			if err != nil {
+				log.Panic(err)
+			}
 		}
 
 		return nil
@@ -120,7 +191,8 @@ func (u UTXOSet) Reindex() {
 	UTXO := u.Blockchain.FindUTXO()
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketName)
+		b := tx.Bucket([]byte(utxoBucket))
+		ib := tx.Bucket([]byte(addrIndexBucket))
 
 		for txID, outs := range UTXO {
 			key, err := hex.DecodeString(txID)
@@ -132,10 +204,20 @@ func (u UTXOSet) Reindex() {
 			if err != nil {
 				log.Panic(err)
 			}
+
+			for outIdx, out := range outs.Outputs {
+				err = ib.Put(addrIndexKey(out.ScriptPubKey, key, outIdx), out.Serialize())
+				if err != nil {
+					log.Panic(err)
+				}
+			}
 		}
 
-		return nil
+		return tx.Bucket([]byte(blocksBucket)).Put([]byte(chainstateVersionKey), []byte{chainstateVersion})
 	})
+	if err != nil {
+		log.Panic(err)
+	}
 }
 
 // Update updates the UTXO set with transactions from the Block
@@ -145,27 +227,30 @@ func (u UTXOSet) Update(block *Block) {
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		ib := tx.Bucket([]byte(addrIndexBucket))
 
 		for _, tx := range block.Transactions {
 			if tx.IsCoinbase() == false {
 				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
 					outsBytes := b.Get(vin.Txid)
 					outs := DeserializeOutputs(outsBytes)
 
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+					spentOut, ok := outs.Outputs[vin.Vout]
+					if ok {
+						err := ib.Delete(addrIndexKey(spentOut.ScriptPubKey, vin.Txid, vin.Vout))
+						if err != nil {
+							log.Panic(err)
 						}
 					}
+					delete(outs.Outputs, vin.Vout)
 
-					if len(updatedOuts.Outputs) == 0 {
+					if len(outs.Outputs) == 0 {
 						err := b.Delete(vin.Txid)
 						if err != nil {
 							log.Panic(err)
 						}
 					} else {
-						err := b.Put(vin.Txid, updatedOuts.Serialize())
+						err := b.Put(vin.Txid, outs.Serialize())
 						if err != nil {
 							log.Panic(err)
 						}
@@ -174,9 +259,22 @@ func (u UTXOSet) Update(block *Block) {
 				}
 			}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs := TXOutputs{make(map[int]TXOutput), block.Height, block.Timestamp}
+			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
+				newOutputs.Outputs[outIdx] = out
+
+				err := ib.Put(addrIndexKey(out.ScriptPubKey, tx.ID, outIdx), out.Serialize())
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
 
 			err := b.Put(tx.ID, newOutputs.Serialize())
@@ -191,3 +289,29 @@ func (u UTXOSet) Update(block *Block) {
 		log.Panic(err)
 	}
 }
+
+// addrIndexPrefix returns the address index prefix of outputs locked with a script
+func addrIndexPrefix(lockingScript []byte) []byte {
+	hash := sha256.Sum256(lockingScript)
+
+	return hash[:]
+}
+
+// addrIndexKey builds an address index key: the locking script hash followed by the outpoint
+func addrIndexKey(lockingScript, txID []byte, outIdx int) []byte {
+	key := addrIndexPrefix(lockingScript)
+	key = append(key, txID...)
+
+	vout := make([]byte, 4)
+	binary.BigEndian.PutUint32(vout, uint32(outIdx))
+
+	return append(key, vout...)
+}
+
+// parseAddrIndexKey extracts the outpoint from an address index key
+func parseAddrIndexKey(key []byte) ([]byte, int) {
+	txID := key[sha256.Size : len(key)-4]
+	outIdx := int(binary.BigEndian.Uint32(key[len(key)-4:]))
+
+	return txID, outIdx
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+// chdirTemp changes to a temporary directory until the test ends, for the files the test creates
+func chdirTemp(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// newTestBlockchain creates a blockchain in a temporary directory, with the genesis
+// coinbase paid to the wallet
+func newTestBlockchain(t *testing.T, wallet *Wallet) *Blockchain {
+	chdirTemp(t)
+
+	bc := CreateBlockchain(string(wallet.GetAddress()), "test")
+	t.Cleanup(func() { bc.db.Close() })
+	UTXOSet{bc}.Reindex()
+
+	return bc
+}
+
+// assertAddrIndexConsistent checks that the address index holds exactly the outputs
+// of the chainstate
+func assertAddrIndexConsistent(t *testing.T, bc *Blockchain) {
+	expected := make(map[string]string)
+	indexed := make(map[string]string)
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
+			for outIdx, out := range DeserializeOutputs(v).Outputs {
+				key := addrIndexKey(out.ScriptPubKey, k, outIdx)
+				expected[hex.EncodeToString(key)] = hex.EncodeToString(out.Serialize())
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(addrIndexBucket)).ForEach(func(k, v []byte) error {
+			indexed[hex.EncodeToString(k)] = hex.EncodeToString(v)
+
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, expected)
+	assert.Equal(t, expected, indexed)
+}
+
+func TestAddrIndex(t *testing.T) {
+	wallet := NewWallet(SchemeP256)
+	to := NewWallet(SchemeP256)
+	bc := newTestBlockchain(t, wallet)
+	UTXOSet := UTXOSet{bc}
+	assertAddrIndexConsistent(t, bc)
+
+	for _, spend := range []struct {
+		from, to *Wallet
+		amount   Amount
+	}{{wallet, to, 3 * coin}, {to, wallet, 2 * coin}} {
+		tx, err := NewUTXOTransaction(spend.from, string(spend.to.GetAddress()), spend.amount, nil, 0, defaultCoinSelector, 0, &UTXOSet)
+		assert.Nil(t, err)
+		block, err := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), tx})
+		assert.Nil(t, err)
+
+		UTXOSet.Update(block)
+		assertAddrIndexConsistent(t, bc)
+	}
+
+	toScript := NewP2PKHScript(HashPubKey(to.PublicKey))
+	assert.Equal(t, []TXOutput{{coin, toScript}}, UTXOSet.FindUTXO(toScript))
+
+	UTXOSet.Reindex()
+	assertAddrIndexConsistent(t, bc)
+	assert.Equal(t, []TXOutput{{coin, toScript}}, UTXOSet.FindUTXO(toScript))
+}
+
+func TestReindexOnOpen(t *testing.T) {
+	for name, outdate := range map[string]func(tx *bolt.Tx) error{
+		"without the address index": func(tx *bolt.Tx) error {
+			return tx.DeleteBucket([]byte(addrIndexBucket))
+		},
+		"in an older chainstate format": func(tx *bolt.Tx) error {
+			err := tx.Bucket([]byte(blocksBucket)).Delete([]byte(chainstateVersionKey))
+			if err != nil {
+				return err
+			}
+			err = tx.DeleteBucket([]byte(utxoBucket))
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateBucket([]byte(utxoBucket))
+
+			return err
+		},
+	} {
+		wallet := NewWallet(SchemeP256)
+		bc := newTestBlockchain(t, wallet)
+		script := NewP2PKHScript(HashPubKey(wallet.PublicKey))
+
+		assert.Nil(t, bc.db.Update(outdate))
+		bc.db.Close()
+
+		bc = NewBlockchain("test")
+		assertAddrIndexConsistent(t, bc)
+		assert.Equal(t, []TXOutput{{subsidy, script}}, UTXOSet{bc}.FindUTXO(script), "Opening a database %s reindexes it", name)
+		bc.db.Close()
+	}
+}
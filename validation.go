@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// TxNotFoundError is returned when a transaction, such as one spent by an input, isn't in the blockchain
+type TxNotFoundError struct {
+	TxID []byte
+}
+
+func (e TxNotFoundError) Error() string {
+	return fmt.Sprintf("Transaction %x is not found", e.TxID)
+}
+
+// ValidationError is the reason a transaction or block breaks the consensus rules.
+// Unlike a PolicyError, it makes a block containing the transaction invalid
+type ValidationError struct {
+	Reason string
+	Detail string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+func validationError(reason, format string, a ...interface{}) error {
+	return ValidationError{reason, fmt.Sprintf(format, a...)}
+}
+
+// prevOutput returns the output spent by an input, taken from prevTXs, the transactions
+// spent by the inputs of its transaction
+func prevOutput(prevTXs map[string]Transaction, vin TXInput) (TXOutput, error) {
+	prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+	if prevTx.ID == nil {
+		return TXOutput{}, TxNotFoundError{vin.Txid}
+	}
+
+	if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+		return TXOutput{}, validationError("bad-outpoint", "transaction %x has no output %d", vin.Txid, vin.Vout)
+	}
+
+	return prevTx.Vout[vin.Vout], nil
+}
+
+// UTXOView finds unspent outputs. UTXOSet is the view of the confirmed chain
+type UTXOView interface {
+	FindOutput(txID []byte, outIdx int) (TXOutput, error)
+}
+
+// CheckTransaction checks the rules a transaction must follow regardless of the chain:
+// it has inputs and outputs, spends no output twice, and its output values are neither
+// negative nor add up to more than maxMoney
+func CheckTransaction(tx *Transaction) error {
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return validationError("empty", "transaction %x needs inputs and outputs", tx.ID)
+	}
+	if hash := tx.Hash(); !bytes.Equal(tx.ID, hash) {
+		return validationError("bad-txid", "transaction %x has hash %x", tx.ID, hash)
+	}
+
+	var total Amount
+	for i, out := range tx.Vout {
+		if out.Value < 0 {
+			return validationError("negative-value", "output %d of transaction %x is worth %s", i, tx.ID, out.Value)
+		}
+
+		var err error
+		if total, err = total.Add(out.Value); err != nil {
+			return validationError("value-overflow", "outputs of transaction %x are worth more than %s", tx.ID, maxMoney)
+		}
+	}
+
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	spent := make(map[string]bool)
+	for i, vin := range tx.Vin {
+		key := outpoint(vin.Txid, vin.Vout)
+		if spent[key] {
+			return validationError("duplicate-inputs", "input %d of transaction %x spends %s again", i, tx.ID, key)
+		}
+		spent[key] = true
+	}
+
+	return nil
+}
+
+// CheckTransactionInputs checks that every input of tx spends an output that is unspent in
+// view, and that the inputs are worth at least the outputs. It returns the fee: what the
+// inputs are worth beyond the outputs
+func CheckTransactionInputs(tx *Transaction, view UTXOView) (Amount, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	var in Amount
+	for i, vin := range tx.Vin {
+		prevOut, err := view.FindOutput(vin.Txid, vin.Vout)
+		if err != nil {
+			return 0, validationError("missing-inputs", "input %d of transaction %x spends %s, which is not an unspent output", i, tx.ID, outpoint(vin.Txid, vin.Vout))
+		}
+		if in, err = in.Add(prevOut.Value); err != nil {
+			return 0, validationError("value-overflow", "inputs of transaction %x are worth more than %s", tx.ID, maxMoney)
+		}
+	}
+
+	var out Amount
+	for _, vout := range tx.Vout {
+		var err error
+		if out, err = out.Add(vout.Value); err != nil {
+			return 0, validationError("value-overflow", "outputs of transaction %x are worth more than %s", tx.ID, maxMoney)
+		}
+	}
+
+	if in < out {
+		return 0, validationError("insufficient-inputs", "transaction %x spends %s with inputs worth %s", tx.ID, out, in)
+	}
+
+	return in - out, nil
+}
+
+// CheckBlockTransactions checks the transactions of a block against view, the UTXO set the
+// block builds on. The first transaction must be the only coinbase, paying at most the
+// subsidy and the fees of the others. A transaction can spend outputs of earlier
+// transactions in the block, but no output can be spent twice
+func CheckBlockTransactions(transactions []*Transaction, view UTXOView) error {
+	if len(transactions) == 0 || !transactions[0].IsCoinbase() {
+		return validationError("bad-coinbase", "first transaction is not a coinbase")
+	}
+
+	blockView := newBlockView(view)
+	var fees Amount
+
+	for i, tx := range transactions {
+		if i > 0 && tx.IsCoinbase() {
+			return validationError("bad-coinbase", "transaction %x is a second coinbase", tx.ID)
+		}
+		if err := CheckTransaction(tx); err != nil {
+			return err
+		}
+		for _, vin := range tx.Vin {
+			if key := outpoint(vin.Txid, vin.Vout); !tx.IsCoinbase() && blockView.spent[key] {
+				return validationError("duplicate-inputs", "transaction %x spends %s, spent earlier in the block", tx.ID, key)
+			}
+		}
+
+		fee, err := CheckTransactionInputs(tx, blockView)
+		if err != nil {
+			return err
+		}
+		if fees, err = fees.Add(fee); err != nil {
+			return validationError("value-overflow", "fees of the block are worth more than %s", maxMoney)
+		}
+
+		blockView.apply(tx)
+	}
+
+	var reward Amount
+	for _, out := range transactions[0].Vout {
+		reward += out.Value
+	}
+	if reward > subsidy+fees {
+		return validationError("coinbase-value", "coinbase pays %s, more than the subsidy and fees of %s", reward, subsidy+fees)
+	}
+
+	return nil
+}
+
+// blockView is the UTXO set seen by a transaction in a block: the outputs of earlier
+// transactions in the block are added to view, and the outputs they spend removed
+type blockView struct {
+	view    UTXOView
+	created map[string]TXOutput
+	spent   map[string]bool
+}
+
+func newBlockView(view UTXOView) *blockView {
+	return &blockView{view, make(map[string]TXOutput), make(map[string]bool)}
+}
+
+// FindOutput implements UTXOView
+func (v *blockView) FindOutput(txID []byte, outIdx int) (TXOutput, error) {
+	key := outpoint(txID, outIdx)
+
+	if v.spent[key] {
+		return TXOutput{}, errors.New("Output is already spent in the block")
+	}
+	if out, ok := v.created[key]; ok {
+		return out, nil
+	}
+
+	return v.view.FindOutput(txID, outIdx)
+}
+
+// apply spends the inputs of tx and adds its outputs
+func (v *blockView) apply(tx *Transaction) {
+	if !tx.IsCoinbase() {
+		for _, vin := range tx.Vin {
+			v.spent[outpoint(vin.Txid, vin.Vout)] = true
+		}
+	}
+
+	for i, out := range tx.Vout {
+		v.created[outpoint(tx.ID, i)] = out
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUTXOView is a UTXO set held in memory, keyed by outpoint
+type fakeUTXOView map[string]TXOutput
+
+func (v fakeUTXOView) FindOutput(txID []byte, outIdx int) (TXOutput, error) {
+	out, ok := v[outpoint(txID, outIdx)]
+	if !ok {
+		return out, errors.New("Output is not found or already spent")
+	}
+
+	return out, nil
+}
+
+func assertReason(t *testing.T, reason string, err error) {
+	if assert.IsType(t, ValidationError{}, err) {
+		assert.Equal(t, reason, err.(ValidationError).Reason)
+	}
+}
+
+func TestCheckTransactionInputs(t *testing.T) {
+	script := NewP2PKHScript(make([]byte, 20))
+	view := fakeUTXOView{
+		outpoint([]byte("tx 1"), 0): {5, script},
+		outpoint([]byte("tx 1"), 1): {3, script},
+	}
+	newTx := func(value Amount, inputs ...int) *Transaction {
+		tx := &Transaction{Vout: []TXOutput{{value, script}}}
+		for _, vout := range inputs {
+			tx.Vin = append(tx.Vin, TXInput{[]byte("tx 1"), vout, nil, sequenceFinal})
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+
+	fee, err := CheckTransactionInputs(newTx(7, 0, 1), view)
+	assert.Nil(t, err)
+	assert.Equal(t, Amount(1), fee)
+
+	_, err = CheckTransactionInputs(newTx(9, 0, 1), view)
+	assertReason(t, "insufficient-inputs", err)
+	_, err = CheckTransactionInputs(newTx(1, 2), view)
+	assertReason(t, "missing-inputs", err)
+
+	assertReason(t, "duplicate-inputs", CheckTransaction(newTx(10, 0, 0)))
+	assertReason(t, "negative-value", CheckTransaction(newTx(-1, 0)))
+	assertReason(t, "empty", CheckTransaction(newTx(1)))
+	assert.Nil(t, CheckTransaction(newTx(8, 0, 1)))
+
+	tx := newTx(8, 0, 1)
+	tx.Vout[0].Value = 7
+	assertReason(t, "bad-txid", CheckTransaction(tx))
+	tx.ID = []byte("tx 2")
+	assertReason(t, "bad-txid", CheckTransaction(tx))
+}
+
+func TestCheckBlockTransactions(t *testing.T) {
+	address := string(encodeAddress(version, make([]byte, 20)))
+	script := lockingScript([]byte(address))
+	view := fakeUTXOView{outpoint([]byte("tx 1"), 0): {5, script}}
+
+	spend := func(txID []byte, value Amount) *Transaction {
+		tx := &Transaction{nil, []TXInput{{txID, 0, nil, sequenceFinal}}, []TXOutput{{value, script}}, 0}
+		tx.ID = tx.Hash()
+		return tx
+	}
+	coinbase := NewCoinbaseTX(address, "")
+	parent := spend([]byte("tx 1"), 4)
+	child := spend(parent.ID, 3)
+
+	assert.Nil(t, CheckBlockTransactions([]*Transaction{coinbase, parent, child}, view), "Outputs of earlier transactions in the block can be spent")
+	assertReason(t, "missing-inputs", CheckBlockTransactions([]*Transaction{coinbase, child, parent}, view))
+	assertReason(t, "duplicate-inputs", CheckBlockTransactions([]*Transaction{coinbase, parent, spend([]byte("tx 1"), 2)}, view))
+	assertReason(t, "bad-coinbase", CheckBlockTransactions([]*Transaction{parent}, view))
+	assertReason(t, "bad-coinbase", CheckBlockTransactions([]*Transaction{coinbase, NewCoinbaseTX(address, "")}, view))
+
+	greedy := NewCoinbaseTX(address, "")
+	greedy.Vout[0].Value = subsidy + 2
+	greedy.ID = greedy.Hash()
+	assert.Nil(t, CheckBlockTransactions([]*Transaction{greedy, parent, child}, view), "Coinbase can claim the fees")
+	greedy.Vout[0].Value = subsidy + 3
+	greedy.ID = greedy.Hash()
+	assertReason(t, "coinbase-value", CheckBlockTransactions([]*Transaction{greedy, parent, child}, view))
+}
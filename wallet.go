@@ -2,9 +2,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"log"
 
@@ -12,18 +9,22 @@ import (
 )
 
 const version = byte(0x00)
+const secp256k1Version = byte(0x3a)
+const schnorrVersion = byte(0x3b)
+const multisigVersion = byte(0x32)
+const scriptHashVersion = byte(0x05)
 const addressChecksumLen = 4
 
 // Wallet stores private and public keys
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
+	PrivateKey PrivateKey
 	PublicKey  []byte
 }
 
-// NewWallet creates and returns a Wallet
-func NewWallet() *Wallet {
-	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+// NewWallet creates and returns a Wallet with a key of the given scheme
+func NewWallet(scheme KeyScheme) *Wallet {
+	private := NewPrivateKey(scheme)
+	wallet := Wallet{private, private.PublicKey()}
 
 	return &wallet
 }
@@ -32,7 +33,22 @@ func NewWallet() *Wallet {
 func (w Wallet) GetAddress() []byte {
 	pubKeyHash := HashPubKey(w.PublicKey)
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+	return encodeAddress(pubKeyScheme(w.PublicKey).addressVersion(), pubKeyHash)
+}
+
+// MultisigAddress returns the address of a multisig locking script
+func MultisigAddress(script []byte) []byte {
+	return encodeAddress(multisigVersion, script)
+}
+
+// ScriptHashAddress returns the P2SH address committing to a redeem script
+func ScriptHashAddress(redeemScript []byte) []byte {
+	return encodeAddress(scriptHashVersion, HashPubKey(redeemScript))
+}
+
+// encodeAddress returns the Base58Check encoding of a versioned payload
+func encodeAddress(addressVersion byte, payload []byte) []byte {
+	versionedPayload := append([]byte{addressVersion}, payload...)
 	checksum := checksum(versionedPayload)
 
 	fullPayload := append(versionedPayload, checksum...)
@@ -41,6 +57,13 @@ func (w Wallet) GetAddress() []byte {
 	return address
 }
 
+// decodeAddress returns the version and payload of an address
+func decodeAddress(address []byte) (byte, []byte) {
+	decoded := Base58Decode(address)
+
+	return decoded[0], decoded[1 : len(decoded)-addressChecksumLen]
+}
+
 // HashPubKey hashes public key
 func HashPubKey(pubKey []byte) []byte {
 	publicSHA256 := sha256.Sum256(pubKey)
@@ -57,13 +80,37 @@ func HashPubKey(pubKey []byte) []byte {
 
 // ValidateAddress check if address if valid
 func ValidateAddress(address string) bool {
+	if len(address) == 0 {
+		return false
+	}
+
 	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) <= 1+addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
+	addressVersion := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
+	targetChecksum := checksum(append([]byte{addressVersion}, pubKeyHash...))
 
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	if bytes.Compare(actualChecksum, targetChecksum) != 0 {
+		return false
+	}
+
+	switch addressVersion {
+	case version, secp256k1Version, schnorrVersion, scriptHashVersion:
+		return len(pubKeyHash) == 20
+	case multisigVersion:
+		m, _ := extractMultisig(pubKeyHash)
+		return m > 0
+	default:
+		return false
+	}
+}
+
+// isKeyHashVersion checks whether addresses of the version pay to a public key hash
+func isKeyHashVersion(addressVersion byte) bool {
+	return addressVersion == version || addressVersion == secp256k1Version || addressVersion == schnorrVersion
 }
 
 // Checksum generates a checksum for a public key
@@ -73,14 +120,3 @@ func checksum(payload []byte) []byte {
 
 	return secondSHA[:addressChecksumLen]
 }
-
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err != nil {
-		log.Panic(err)
-	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-
-	return *private, pubKey
-}
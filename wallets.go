@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
 	"fmt"
 	"io/ioutil"
@@ -12,24 +11,26 @@ import (
 
 const walletFile = "wallet_%s.dat"
 
-// Wallets stores a collection of wallets
+// Wallets stores a collection of wallets and the redeem scripts of P2SH addresses
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets       map[string]*Wallet
+	RedeemScripts map[string][]byte
 }
 
 // NewWallets creates Wallets and fills it from a file if it exists
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.RedeemScripts = make(map[string][]byte)
 
 	err := wallets.LoadFromFile(nodeID)
 
 	return &wallets, err
 }
 
-// CreateWallet adds a Wallet to Wallets
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+// CreateWallet adds a Wallet with a key of the given scheme to Wallets
+func (ws *Wallets) CreateWallet(scheme KeyScheme) string {
+	wallet := NewWallet(scheme)
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
@@ -37,6 +38,15 @@ func (ws *Wallets) CreateWallet() string {
 	return address
 }
 
+// AddRedeemScript stores a redeem script and returns its P2SH address
+func (ws *Wallets) AddRedeemScript(redeemScript []byte) string {
+	address := fmt.Sprintf("%s", ScriptHashAddress(redeemScript))
+
+	ws.RedeemScripts[address] = redeemScript
+
+	return address
+}
+
 // GetAddresses returns an array of addresses stored in the wallet file
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
@@ -45,12 +55,32 @@ func (ws *Wallets) GetAddresses() []string {
 		addresses = append(addresses, address)
 	}
 
+	for address := range ws.RedeemScripts {
+		addresses = append(addresses, address)
+	}
+
 	return addresses
 }
 
 // GetWallet returns a Wallet by its address
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("Address %s is not in the wallet file", address)
+	}
+
+	return *wallet, nil
+}
+
+// GetWalletByPubKeyHash returns the Wallet whose public key has the given hash
+func (ws Wallets) GetWalletByPubKeyHash(pubKeyHash []byte) (Wallet, bool) {
+	for _, wallet := range ws.Wallets {
+		if bytes.Equal(HashPubKey(wallet.PublicKey), pubKeyHash) {
+			return *wallet, true
+		}
+	}
+
+	return Wallet{}, false
 }
 
 // LoadFromFile loads wallets from the file
@@ -66,7 +96,6 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if err != nil {
@@ -74,6 +103,9 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	ws.Wallets = wallets.Wallets
+	if wallets.RedeemScripts != nil {
+		ws.RedeemScripts = wallets.RedeemScripts
+	}
 
 	return nil
 }
@@ -83,8 +115,6 @@ func (ws Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
 	walletFile := fmt.Sprintf(walletFile, nodeID)
 
-	gob.Register(elliptic.P256())
-
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
 	if err != nil {